@@ -35,11 +35,38 @@ func Setup() {
 
 	reportError(DefaultClient.AutoMigrate(&models.User{}))
 	reportError(DefaultClient.AutoMigrate(&models.Post{}))
-	reportError(DefaultClient.AutoMigrate(&models.Like{}))
+	backfillPublishAt()
+	reportError(DefaultClient.AutoMigrate(&models.PostAttachment{}))
+	reportError(DefaultClient.AutoMigrate(&models.PostRevision{}))
+	reportError(DefaultClient.AutoMigrate(&models.Poll{}))
+	reportError(DefaultClient.AutoMigrate(&models.PollOption{}))
+	reportError(DefaultClient.AutoMigrate(&models.PollVote{}))
+	reportError(DefaultClient.AutoMigrate(&models.PostStat{}))
+	reportError(DefaultClient.AutoMigrate(&models.AuthorStat{}))
+	reportError(DefaultClient.AutoMigrate(&models.StatsFlush{}))
+	reportError(DefaultClient.AutoMigrate(&models.Reaction{}))
+	migrateLikes()
 	reportError(DefaultClient.AutoMigrate(&models.Comment{}))
 	reportError(DefaultClient.AutoMigrate(&models.Chat{}))
 	reportError(DefaultClient.AutoMigrate(&models.ChatUser{}))
 	reportError(DefaultClient.AutoMigrate(&models.Message{}))
+	reportError(DefaultClient.AutoMigrate(&models.MessageDeletion{}))
+	reportError(DefaultClient.AutoMigrate(&models.MessageReaction{}))
+	reportError(DefaultClient.AutoMigrate(&models.Asset{}))
+	reportError(DefaultClient.AutoMigrate(&models.Follow{}))
+	reportError(DefaultClient.AutoMigrate(&models.UserExpertise{}))
+	reportError(DefaultClient.AutoMigrate(&models.Tag{}))
+	reportError(DefaultClient.AutoMigrate(&models.PostTag{}))
+	reportError(DefaultClient.AutoMigrate(&models.TagFollow{}))
+	reportError(DefaultClient.AutoMigrate(&models.Mention{}))
+	reportError(DefaultClient.AutoMigrate(&models.LinkPreview{}))
+	reportError(DefaultClient.AutoMigrate(&models.Link{}))
+	reportError(DefaultClient.AutoMigrate(&models.Notification{}))
+	reportError(DefaultClient.AutoMigrate(&models.NotificationPreference{}))
+	reportError(DefaultClient.AutoMigrate(&models.BookmarkCollection{}))
+	reportError(DefaultClient.AutoMigrate(&models.Bookmark{}))
+	reportError(DefaultClient.AutoMigrate(&models.Report{}))
+	reportError(DefaultClient.AutoMigrate(&models.ModerationAction{}))
 
 	DefaultCache, err = NewRedisClient()
 	if err == nil {
@@ -49,6 +76,35 @@ func Setup() {
 	}
 }
 
+// migrateLikes moves the likes written before typed reactions existed into
+// the reactions table, the old table is kept renamed as a backup.
+func migrateLikes() {
+	migrator := DefaultClient.Migrator()
+	if !migrator.HasTable("likes") {
+		return
+	}
+
+	err := DefaultClient.Exec(
+		"INSERT INTO reactions (target_type, target_id, author_id, type, creation_at, updated_at) " +
+			"SELECT 'post', post_id, author_id, 'like', min(creation_at), now() FROM likes " +
+			"WHERE deleted_at IS NULL GROUP BY post_id, author_id " +
+			"ON CONFLICT DO NOTHING",
+	).Error
+	if err != nil {
+		reportError(err)
+		return
+	}
+	reportError(migrator.RenameTable("likes", "likes_legacy"))
+}
+
+// backfillPublishAt dates the posts published before drafts existed by
+// their creation, feeds are ordered by publish time.
+func backfillPublishAt() {
+	reportError(DefaultClient.Exec(
+		"UPDATE posts SET publish_at = creation_at WHERE publish_at IS NULL AND status = 'published'",
+	).Error)
+}
+
 func NewClient() (*gorm.DB, error) {
 	driver := os.Getenv("DATABASE_DRIVER")
 	url := os.Getenv("DATABASE_URL")
@@ -11,6 +11,7 @@ import (
 	"github.com/juliotorresmoreno/specialist-talk-api/logger"
 	"github.com/juliotorresmoreno/specialist-talk-api/server"
 	"github.com/juliotorresmoreno/specialist-talk-api/server/events"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/posts"
 )
 
 func main() {
@@ -23,6 +24,8 @@ func main() {
 	logger.SetupLogrus()
 	db.Setup()
 	events.Setup()
+	posts.StartScheduler()
+	posts.StartAnalytics()
 
 	r := gin.Default()
 	server.SetupAPIRoutes(r.Group("/api"))
@@ -1,7 +1,10 @@
 package miniostorage
 
 import (
+	"context"
+	"net/url"
 	"os"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -20,3 +23,37 @@ func NewClient() (*minio.Client, error) {
 
 	return minioClient, err
 }
+
+// PrivateBucket is the bucket for objects that are never served publicly,
+// they can only be read through a presigned URL.
+func PrivateBucket() string {
+	bucket := os.Getenv("MINIO_PRIVATE_BUCKET")
+	if bucket == "" {
+		bucket = os.Getenv("MINIO_BUCKET") + "-private"
+	}
+	return bucket
+}
+
+func PresignedURL(objectName string, expires time.Duration) (*url.URL, error) {
+	client, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.PresignedGetObject(
+		context.Background(), PrivateBucket(), objectName, expires, url.Values{},
+	)
+}
+
+// RemoveObject deletes an object, the presigned URLs issued for it stop
+// working along with it.
+func RemoveObject(bucket, objectName string) error {
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+
+	return client.RemoveObject(
+		context.Background(), bucket, objectName, minio.RemoveObjectOptions{},
+	)
+}
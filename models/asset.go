@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AssetKindChatAttachment = "chat_attachment"
+	AssetKindVerification   = "verification"
+)
+
+type Asset struct {
+	ID         uint           `gorm:"primaryKey"`
+	ObjectName string         `gorm:"type:varchar(200);not null;unique"`
+	Kind       string         `gorm:"type:varchar(30);default:'';not null"`
+	MimeType   string         `gorm:"type:varchar(100);default:'';not null"`
+	Size       int64          `gorm:"not null;default:0"`
+	OwnerId    uint           `gorm:"not null;index"`
+	Owner      User           `gorm:"foreignKey:OwnerId"`
+	ChatId     *uint          `gorm:"index"`
+	Chat       *Chat          `gorm:"foreignKey:ChatId"`
+	CreationAt time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt  gorm.DeletedAt `gorm:"type:timestamptz"`
+}
+
+func (u Asset) TableName() string {
+	return "assets"
+}
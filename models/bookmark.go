@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+type BookmarkCollection struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserId     uint      `gorm:"not null;uniqueIndex:idx_bookmark_collections_user_name"`
+	User       User      `gorm:"foreignKey:UserId"`
+	Name       string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_bookmark_collections_user_name"`
+	CreationAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+func (u BookmarkCollection) TableName() string {
+	return "bookmark_collections"
+}
+
+type Bookmark struct {
+	ID           uint                `gorm:"primaryKey"`
+	UserId       uint                `gorm:"not null;uniqueIndex:idx_bookmarks_user_post"`
+	User         User                `gorm:"foreignKey:UserId"`
+	PostId       uint                `gorm:"not null;uniqueIndex:idx_bookmarks_user_post;index"`
+	Post         Post                `gorm:"foreignKey:PostId"`
+	CollectionId *uint               `gorm:"index"`
+	Collection   *BookmarkCollection `gorm:"foreignKey:CollectionId"`
+	CreationAt   time.Time           `gorm:"autoCreateTime"`
+}
+
+func (u Bookmark) TableName() string {
+	return "bookmarks"
+}
@@ -26,14 +26,16 @@ func (u Chat) TableName() string {
 }
 
 type ChatUser struct {
-	ID         uint           `gorm:"primaryKey"`
-	ChatId     uint           `gorm:"not null"`
-	Chat       Chat           `gorm:"foreignKey:ChatId"`
-	UserId     uint           `gorm:"not null"`
-	User       User           `gorm:"foreignKey:UserId"`
-	CreationAt time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt  time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt  gorm.DeletedAt `gorm:"type:timestamptz"`
+	ID                uint `gorm:"primaryKey"`
+	ChatId            uint `gorm:"not null"`
+	Chat              Chat `gorm:"foreignKey:ChatId"`
+	UserId            uint `gorm:"not null"`
+	User              User `gorm:"foreignKey:UserId"`
+	LastReadMessageId *uint
+	LastReadAt        *time.Time     `gorm:"type:timestamptz"`
+	CreationAt        time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt         gorm.DeletedAt `gorm:"type:timestamptz"`
 }
 
 func (u ChatUser) TableName() string {
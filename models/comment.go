@@ -13,6 +13,11 @@ type Comment struct {
 	Post       Post           `gorm:"foreignKey:PostId"`
 	AuthorId   uint           `gorm:"not null"`
 	Author     User           `gorm:"foreignKey:AuthorId"`
+	ParentId   *uint          `gorm:"index"`
+	Parent     *Comment       `gorm:"foreignKey:ParentId"`
+	Hidden     bool           `gorm:"not null;default:false"`
+	Edited     bool           `gorm:"not null;default:false"`
+	EditedAt   *time.Time     `gorm:"type:timestamptz"`
 	CreationAt time.Time      `gorm:"autoCreateTime"`
 	UpdatedAt  time.Time      `gorm:"autoUpdateTime"`
 	DeletedAt  gorm.DeletedAt `gorm:"type:timestamptz"`
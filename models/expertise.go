@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+type UserExpertise struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserId     uint      `gorm:"not null;uniqueIndex:idx_user_expertises_user_tag"`
+	User       User      `gorm:"foreignKey:UserId"`
+	Tag        string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_user_expertises_user_tag;index"`
+	CreationAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (u UserExpertise) TableName() string {
+	return "user_expertises"
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+type Follow struct {
+	ID         uint      `gorm:"primaryKey"`
+	FollowerId uint      `gorm:"not null;uniqueIndex:idx_follows_follower_followee"`
+	Follower   User      `gorm:"foreignKey:FollowerId"`
+	FolloweeId uint      `gorm:"not null;uniqueIndex:idx_follows_follower_followee;index"`
+	Followee   User      `gorm:"foreignKey:FolloweeId"`
+	CreationAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (u Follow) TableName() string {
+	return "follows"
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	LinkPreviewPending = "pending"
+	LinkPreviewReady   = "ready"
+	LinkPreviewFailed  = "failed"
+)
+
+// LinkPreview is the card of a URL, shared by every post or message that
+// links to it.
+type LinkPreview struct {
+	ID          uint       `gorm:"primaryKey"`
+	URL         string     `gorm:"type:varchar(2048);not null;uniqueIndex"`
+	Status      string     `gorm:"type:varchar(20);not null;default:'pending'"`
+	Title       string     `gorm:"type:varchar(300);not null;default:''"`
+	Description string     `gorm:"type:varchar(1000);not null;default:''"`
+	ImageURL    string     `gorm:"type:varchar(2048);not null;default:''"`
+	SiteName    string     `gorm:"type:varchar(200);not null;default:''"`
+	FetchedAt   *time.Time `gorm:"type:timestamptz"`
+	CreationAt  time.Time  `gorm:"autoCreateTime"`
+}
+
+func (u LinkPreview) TableName() string {
+	return "link_previews"
+}
+
+// Link ties a URL found in some content to its preview, sources are the
+// same as for mentions.
+type Link struct {
+	ID            uint        `gorm:"primaryKey"`
+	SourceType    string      `gorm:"type:varchar(20);not null;index:idx_links_source,priority:1"`
+	SourceId      uint        `gorm:"not null;index:idx_links_source,priority:2"`
+	LinkPreviewId uint        `gorm:"not null;index"`
+	LinkPreview   LinkPreview `gorm:"foreignKey:LinkPreviewId"`
+	Position      int         `gorm:"not null"`
+	CreationAt    time.Time   `gorm:"autoCreateTime"`
+}
+
+func (u Link) TableName() string {
+	return "links"
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	MentionSourcePost    = "post"
+	MentionSourceComment = "comment"
+	MentionSourceMessage = "message"
+)
+
+type Mention struct {
+	ID         uint      `gorm:"primaryKey"`
+	SourceType string    `gorm:"type:varchar(20);not null;index:idx_mentions_source,priority:1"`
+	SourceId   uint      `gorm:"not null;index:idx_mentions_source,priority:2"`
+	UserId     uint      `gorm:"not null;index"`
+	User       User      `gorm:"foreignKey:UserId"`
+	Start      int       `gorm:"not null"`
+	End        int       `gorm:"not null"`
+	CreationAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (u Mention) TableName() string {
+	return "mentions"
+}
@@ -6,14 +6,53 @@ import (
 	"gorm.io/gorm"
 )
 
+// Message is sent to a chat. ReplyToMessageId is the message it quotes and
+// ThreadRootId the message whose side thread it belongs to, thread messages
+// stay out of the main timeline.
 type Message struct {
-	ID         uint           `gorm:"primaryKey"`
-	ChatId     uint           `gorm:"not null"`
-	Chat       Chat           `gorm:"foreignKey:ChatId"`
-	UserId     uint           `gorm:"not null"`
-	User       User           `gorm:"foreignKey:UserId"`
-	Content    string         `gorm:"not null"`
-	CreationAt time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt  time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt  gorm.DeletedAt `gorm:"type:timestamptz"`
+	ID               uint           `gorm:"primaryKey;index:idx_messages_chat_id_id,priority:2;index:idx_messages_thread_root_id_id,priority:2"`
+	ChatId           uint           `gorm:"not null;index:idx_messages_chat_id_id,priority:1"`
+	Chat             Chat           `gorm:"foreignKey:ChatId"`
+	UserId           uint           `gorm:"not null"`
+	User             User           `gorm:"foreignKey:UserId"`
+	Content          string         `gorm:"not null"`
+	ReplyToMessageId *uint          `gorm:"index"`
+	ThreadRootId     *uint          `gorm:"index:idx_messages_thread_root_id_id,priority:1"`
+	Hidden           bool           `gorm:"not null;default:false"`
+	Edited           bool           `gorm:"not null;default:false"`
+	EditedAt         *time.Time     `gorm:"type:timestamptz"`
+	CreationAt       time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt        time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt        gorm.DeletedAt `gorm:"type:timestamptz"`
+}
+
+// MessageDeletion hides a message from a single member, the one that
+// deleted it for itself.
+type MessageDeletion struct {
+	ID         uint      `gorm:"primaryKey"`
+	MessageId  uint      `gorm:"not null;uniqueIndex:idx_message_deletions_message_id_user_id"`
+	Message    Message   `gorm:"foreignKey:MessageId"`
+	UserId     uint      `gorm:"not null;uniqueIndex:idx_message_deletions_message_id_user_id"`
+	User       User      `gorm:"foreignKey:UserId"`
+	CreationAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (u MessageDeletion) TableName() string {
+	return "message_deletions"
+}
+
+// MessageReaction is an emoji a member put on a message, each member can
+// use every emoji once.
+type MessageReaction struct {
+	ID         uint      `gorm:"primaryKey"`
+	MessageId  uint      `gorm:"not null;uniqueIndex:idx_message_reactions_message_id_user_id_emoji"`
+	Message    Message   `gorm:"foreignKey:MessageId"`
+	UserId     uint      `gorm:"not null;uniqueIndex:idx_message_reactions_message_id_user_id_emoji"`
+	User       User      `gorm:"foreignKey:UserId"`
+	Emoji      string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_message_reactions_message_id_user_id_emoji"`
+	CreationAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (u MessageReaction) TableName() string {
+	return "message_reactions"
 }
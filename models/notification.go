@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	NotificationMention  = "mention"
+	NotificationComment  = "comment"
+	NotificationReply    = "reply"
+	NotificationReaction = "reaction"
+	NotificationFollow   = "follow"
+	NotificationRepost   = "repost"
+	NotificationQuote    = "quote"
+	NotificationReport   = "report"
+	NotificationWarning  = "warning"
+)
+
+var NotificationTypes = []string{
+	NotificationMention,
+	NotificationComment,
+	NotificationReply,
+	NotificationReaction,
+	NotificationFollow,
+	NotificationRepost,
+	NotificationQuote,
+	NotificationReport,
+	NotificationWarning,
+}
+
+type Notification struct {
+	ID         uint       `gorm:"primaryKey;index:idx_notifications_user_id_id,priority:2"`
+	UserId     uint       `gorm:"not null;index:idx_notifications_user_id_id,priority:1"`
+	User       User       `gorm:"foreignKey:UserId"`
+	Type       string     `gorm:"type:varchar(30);not null"`
+	ActorId    uint       `gorm:"not null"`
+	Actor      User       `gorm:"foreignKey:ActorId"`
+	TargetType string     `gorm:"type:varchar(20);not null;default:''"`
+	TargetId   uint       `gorm:"not null;default:0"`
+	ReadAt     *time.Time `gorm:"type:timestamptz"`
+	CreationAt time.Time  `gorm:"autoCreateTime"`
+}
+
+func (u Notification) TableName() string {
+	return "notifications"
+}
+
+// NotificationPreference turns a notification type on or off for a user,
+// types without a row are enabled. Enabled has no column default, gorm would
+// otherwise leave false out of inserts.
+type NotificationPreference struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserId    uint      `gorm:"not null;uniqueIndex:idx_notification_preferences_user_type"`
+	User      User      `gorm:"foreignKey:UserId"`
+	Type      string    `gorm:"type:varchar(30);not null;uniqueIndex:idx_notification_preferences_user_type"`
+	Enabled   bool      `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (u NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
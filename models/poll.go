@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+type Poll struct {
+	ID             uint         `gorm:"primaryKey"`
+	PostId         uint         `gorm:"not null;uniqueIndex"`
+	Post           Post         `gorm:"foreignKey:PostId"`
+	MultipleChoice bool         `gorm:"not null;default:false"`
+	Anonymous      bool         `gorm:"not null;default:false"`
+	ClosesAt       *time.Time   `gorm:"type:timestamptz"`
+	Options        []PollOption `gorm:"foreignKey:PollId"`
+	CreationAt     time.Time    `gorm:"autoCreateTime"`
+}
+
+func (u Poll) TableName() string {
+	return "polls"
+}
+
+type PollOption struct {
+	ID       uint   `gorm:"primaryKey"`
+	PollId   uint   `gorm:"not null;index"`
+	Position int    `gorm:"not null;default:0"`
+	Text     string `gorm:"type:varchar(200);not null"`
+}
+
+func (u PollOption) TableName() string {
+	return "poll_options"
+}
+
+type PollVote struct {
+	ID         uint       `gorm:"primaryKey"`
+	PollId     uint       `gorm:"not null;index"`
+	OptionId   uint       `gorm:"not null;uniqueIndex:idx_poll_votes_option_user"`
+	Option     PollOption `gorm:"foreignKey:OptionId"`
+	UserId     uint       `gorm:"not null;uniqueIndex:idx_poll_votes_option_user;index"`
+	User       User       `gorm:"foreignKey:UserId"`
+	CreationAt time.Time  `gorm:"autoCreateTime"`
+}
+
+func (u PollVote) TableName() string {
+	return "poll_votes"
+}
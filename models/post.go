@@ -6,16 +6,51 @@ import (
 	"gorm.io/gorm"
 )
 
+// A post is only listed in feeds once it is published, scheduled posts
+// are published by the scheduler when publish_at is reached.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
+// Visibility sets the audience of a post: everybody, the author's
+// followers, only the author or the members of a chat.
+const (
+	PostVisibilityPublic    = "public"
+	PostVisibilityFollowers = "followers"
+	PostVisibilityPrivate   = "private"
+	PostVisibilityChat      = "chat"
+)
+
+// A repost shares another post as is, a quote adds content on top of it.
+const (
+	PostKindPost   = "post"
+	PostKindRepost = "repost"
+	PostKindQuote  = "quote"
+)
+
 type Post struct {
-	ID         uint           `gorm:"primaryKey"`
-	Content    string         `gorm:"type:varchar(1000);default:'';not null"`
-	AuthorId   uint           `gorm:"not null"`
-	Author     User           `gorm:"foreignKey:AuthorId"`
-	Likes      []Like         `gorm:"foreignKey:PostId"`
-	Comments   []Comment      `gorm:"foreignKey:PostId"`
-	CreationAt time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
-	UpdatedAt  time.Time      `gorm:"type:timestamptz"`
-	DeletedAt  gorm.DeletedAt `gorm:"type:timestamptz"`
+	ID          uint             `gorm:"primaryKey"`
+	Content     string           `gorm:"type:varchar(1000);default:'';not null"`
+	AuthorId    uint             `gorm:"not null;uniqueIndex:idx_posts_author_id_repost_of_id,where:kind = 'repost' AND deleted_at IS NULL"`
+	Author      User             `gorm:"foreignKey:AuthorId"`
+	Kind        string           `gorm:"type:varchar(20);default:'post';not null"`
+	RepostOfId  *uint            `gorm:"index;uniqueIndex:idx_posts_author_id_repost_of_id"`
+	RepostOf    *Post            `gorm:"foreignKey:RepostOfId"`
+	Comments    []Comment        `gorm:"foreignKey:PostId"`
+	Attachments []PostAttachment `gorm:"foreignKey:PostId"`
+	Status      string           `gorm:"type:varchar(20);default:'published';not null;index"`
+	Visibility  string           `gorm:"type:varchar(20);default:'public';not null"`
+	ChatId      *uint            `gorm:"index"`
+	Chat        *Chat            `gorm:"foreignKey:ChatId"`
+	PublishAt   *time.Time       `gorm:"type:timestamptz;index"`
+	Hidden      bool             `gorm:"not null;default:false"`
+	Edited      bool             `gorm:"not null;default:false"`
+	EditedAt    *time.Time       `gorm:"type:timestamptz"`
+	CreationAt  time.Time        `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt   time.Time        `gorm:"type:timestamptz"`
+	DeletedAt   gorm.DeletedAt   `gorm:"type:timestamptz"`
 }
 
 func (u Post) TableName() string {
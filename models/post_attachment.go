@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PostAttachment struct {
+	ID         uint           `gorm:"primaryKey"`
+	PostId     uint           `gorm:"not null;index"`
+	Post       Post           `gorm:"foreignKey:PostId"`
+	Position   int            `gorm:"not null;default:0"`
+	ObjectName string         `gorm:"type:varchar(200);not null"`
+	URL        string         `gorm:"type:varchar(1000);not null"`
+	MimeType   string         `gorm:"type:varchar(100);default:'';not null"`
+	Size       int64          `gorm:"not null;default:0"`
+	Width      int            `gorm:"not null;default:0"`
+	Height     int            `gorm:"not null;default:0"`
+	AltText    string         `gorm:"type:varchar(1000);default:'';not null"`
+	Text       string         `gorm:"type:text;default:'';not null"`
+	CreationAt time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt  time.Time      `gorm:"type:timestamptz"`
+	DeletedAt  gorm.DeletedAt `gorm:"type:timestamptz"`
+}
+
+func (u PostAttachment) TableName() string {
+	return "post_attachments"
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+type PostRevision struct {
+	ID         uint      `gorm:"primaryKey"`
+	PostId     uint      `gorm:"not null;index"`
+	Post       Post      `gorm:"foreignKey:PostId"`
+	Content    string    `gorm:"type:varchar(1000);default:'';not null"`
+	EditorId   uint      `gorm:"not null"`
+	Editor     User      `gorm:"foreignKey:EditorId"`
+	CreationAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+}
+
+func (u PostRevision) TableName() string {
+	return "post_revisions"
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// PostStat holds the impressions and unique viewers of a post, counted in
+// Redis and flushed periodically.
+type PostStat struct {
+	ID            uint      `gorm:"primaryKey"`
+	PostId        uint      `gorm:"not null;uniqueIndex"`
+	Post          Post      `gorm:"foreignKey:PostId"`
+	Views         int64     `gorm:"not null;default:0"`
+	UniqueViewers int64     `gorm:"not null;default:0"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+func (u PostStat) TableName() string {
+	return "post_stats"
+}
+
+// AuthorStat holds the daily impressions and unique viewers of the posts of
+// an author.
+type AuthorStat struct {
+	ID            uint      `gorm:"primaryKey"`
+	UserId        uint      `gorm:"not null;uniqueIndex:idx_author_stats_user_id_day"`
+	User          User      `gorm:"foreignKey:UserId"`
+	Day           time.Time `gorm:"type:date;not null;uniqueIndex:idx_author_stats_user_id_day"`
+	Views         int64     `gorm:"not null;default:0"`
+	UniqueViewers int64     `gorm:"not null;default:0"`
+}
+
+func (u AuthorStat) TableName() string {
+	return "author_stats"
+}
+
+// StatsFlush marks a batch of view counters as saved, a batch whose copy in
+// Redis could not be cleared is not counted twice.
+type StatsFlush struct {
+	ID         uint      `gorm:"primaryKey"`
+	Batch      string    `gorm:"type:varchar(64);not null;uniqueIndex"`
+	CreationAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP;index"`
+}
+
+func (u StatsFlush) TableName() string {
+	return "stats_flushes"
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ReactionTargetPost    = "post"
+	ReactionTargetComment = "comment"
+)
+
+var ReactionTypes = []string{"like", "insightful", "celebrate", "support", "love", "curious"}
+
+type Reaction struct {
+	ID         uint      `gorm:"primaryKey"`
+	TargetType string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_reactions_target_author,priority:1"`
+	TargetId   uint      `gorm:"not null;uniqueIndex:idx_reactions_target_author,priority:2"`
+	AuthorId   uint      `gorm:"not null;uniqueIndex:idx_reactions_target_author,priority:3"`
+	Author     User      `gorm:"foreignKey:AuthorId"`
+	Type       string    `gorm:"type:varchar(20);not null"`
+	CreationAt time.Time `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
+	UpdatedAt  time.Time `gorm:"type:timestamptz"`
+}
+
+func (u Reaction) TableName() string {
+	return "reactions"
+}
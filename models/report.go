@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+)
+
+// Reports and moderation actions point to their target by type and id.
+const (
+	ModerationTargetPost    = "post"
+	ModerationTargetComment = "comment"
+	ModerationTargetMessage = "message"
+	ModerationTargetUser    = "user"
+)
+
+// ReportReasonAutomated marks the reports opened by the content filter,
+// they have no reporter.
+const ReportReasonAutomated = "automated"
+
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+const (
+	ModerationActionHide    = "hide"
+	ModerationActionDelete  = "delete"
+	ModerationActionWarn    = "warn"
+	ModerationActionSuspend = "suspend"
+	ModerationActionDismiss = "dismiss"
+	ModerationActionRestore = "restore"
+)
+
+type Report struct {
+	ID           uint   `gorm:"primaryKey"`
+	ReporterId   *uint  `gorm:"index"`
+	Reporter     *User  `gorm:"foreignKey:ReporterId"`
+	TargetType   string `gorm:"type:varchar(20);not null;index:idx_reports_target"`
+	TargetId     uint   `gorm:"not null;index:idx_reports_target"`
+	Reason       string `gorm:"type:varchar(30);not null"`
+	Details      string `gorm:"type:varchar(1000);not null;default:''"`
+	Status       string `gorm:"type:varchar(20);not null;default:'open';index"`
+	ResolvedById *uint
+	ResolvedBy   *User      `gorm:"foreignKey:ResolvedById"`
+	ResolvedAt   *time.Time `gorm:"type:timestamptz"`
+	CreationAt   time.Time  `gorm:"autoCreateTime"`
+}
+
+func (u Report) TableName() string {
+	return "reports"
+}
+
+// ModerationAction is the audit trail of every action taken by moderators.
+type ModerationAction struct {
+	ID           uint   `gorm:"primaryKey"`
+	ModeratorId  uint   `gorm:"not null;index"`
+	Moderator    User   `gorm:"foreignKey:ModeratorId"`
+	TargetType   string `gorm:"type:varchar(20);not null;index:idx_moderation_actions_target"`
+	TargetId     uint   `gorm:"not null;index:idx_moderation_actions_target"`
+	TargetUserId uint   `gorm:"not null;index"`
+	Action       string `gorm:"type:varchar(20);not null"`
+	Reason       string `gorm:"type:varchar(1000);not null;default:''"`
+	ReportId     *uint
+	CreationAt   time.Time `gorm:"autoCreateTime"`
+}
+
+func (u ModerationAction) TableName() string {
+	return "moderation_actions"
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+type Tag struct {
+	ID         uint      `gorm:"primaryKey"`
+	Name       string    `gorm:"type:varchar(100);not null;unique"`
+	CreationAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (u Tag) TableName() string {
+	return "tags"
+}
+
+type PostTag struct {
+	ID         uint      `gorm:"primaryKey"`
+	PostId     uint      `gorm:"not null;uniqueIndex:idx_post_tags_post_tag"`
+	Post       Post      `gorm:"foreignKey:PostId"`
+	TagId      uint      `gorm:"not null;uniqueIndex:idx_post_tags_post_tag;index"`
+	Tag        Tag       `gorm:"foreignKey:TagId"`
+	CreationAt time.Time `gorm:"autoCreateTime;index"`
+}
+
+func (u PostTag) TableName() string {
+	return "post_tags"
+}
+
+type TagFollow struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserId     uint      `gorm:"not null;uniqueIndex:idx_tag_follows_user_tag"`
+	User       User      `gorm:"foreignKey:UserId"`
+	TagId      uint      `gorm:"not null;uniqueIndex:idx_tag_follows_user_tag;index"`
+	Tag        Tag       `gorm:"foreignKey:TagId"`
+	CreationAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (u TagFollow) TableName() string {
+	return "tag_follows"
+}
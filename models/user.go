@@ -24,6 +24,9 @@ type User struct {
 	Url            string         `gorm:"type:varchar(1000);default:'';nullable"`
 	Description    string         `gorm:"type:varchar(1000);default:'';nullable"`
 	Rol            string         `gorm:"type:varchar(15);default:''"`
+	SuspendedUntil *time.Time     `gorm:"type:timestamptz"`
+	ShowPresence   bool           `gorm:"not null;default:true"`
+	LastSeenAt     *time.Time     `gorm:"type:timestamptz"`
 	Chats          []Chat         `gorm:"foreignKey:OwnerId"`
 	ChatUsers      []ChatUser     `gorm:"foreignKey:UserId"`
 	CreationAt     time.Time      `gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
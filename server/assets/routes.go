@@ -0,0 +1,325 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/logger"
+	"github.com/juliotorresmoreno/specialist-talk-api/miniostorage"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"github.com/minio/minio-go/v7"
+)
+
+var log = logger.SetupLogger()
+
+// URLExpiration is how long a signed asset URL stays valid.
+const URLExpiration = 15 * time.Minute
+
+const maxAssetSize = 10 << 20
+
+// allowedTypes are the content types an asset can be stored with. The type
+// is sniffed from the file itself, the one the client declares is ignored
+// so no markup can be served from the bucket domain.
+var allowedTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"video/mp4":       true,
+	"video/webm":      true,
+	"audio/mpeg":      true,
+}
+
+type AssetsRouter struct{}
+
+func SetupAPIRoutes(g *gin.RouterGroup) {
+	h := &AssetsRouter{}
+
+	g.POST("", h.create)
+	g.GET("/:id", h.findOne)
+	g.GET("/:id/download", h.download)
+	g.DELETE("/:id", h.delete)
+}
+
+type Asset struct {
+	ID         uint      `json:"id"`
+	Kind       string    `json:"kind"`
+	MimeType   string    `json:"mime_type"`
+	Size       int64     `json:"size"`
+	OwnerId    uint      `json:"owner_id"`
+	ChatId     *uint     `json:"chat_id,omitempty"`
+	URL        string    `json:"url" gorm:"-"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"-"`
+	CreationAt time.Time `json:"creation_at"`
+}
+
+type CreatePayload struct {
+	File   string `json:"file" validate:"required"`
+	Kind   string `json:"kind" validate:"required,oneof=chat_attachment verification"`
+	ChatId *uint  `json:"chat_id"`
+}
+
+type CreateErrors struct {
+	File   string `json:"file,omitempty"`
+	Kind   string `json:"kind,omitempty"`
+	ChatId string `json:"chat_id,omitempty"`
+}
+
+func (h *AssetsRouter) create(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := &CreatePayload{}
+	err = c.ShouldBind(payload)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "Invalid payload",
+		})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		customErrors := CreateErrors{
+			File: errorsMap["File"],
+			Kind: errorsMap["Kind"],
+		}
+		c.JSON(http.StatusBadRequest, customErrors)
+		return
+	}
+
+	if payload.Kind == models.AssetKindChatAttachment {
+		if payload.ChatId == nil {
+			c.JSON(http.StatusBadRequest, CreateErrors{
+				ChatId: "This field is required!",
+			})
+			return
+		}
+		if !memberOfChat(*payload.ChatId, session.ID) {
+			c.JSON(401, gin.H{"message": "Unauthorized"})
+			return
+		}
+	} else {
+		payload.ChatId = nil
+	}
+
+	attachment, err := utils.ParseBase64File(payload.File)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, CreateErrors{File: "Invalid field!"})
+		return
+	}
+	decoded, err := base64.StdEncoding.DecodeString(attachment)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, CreateErrors{File: "Invalid field!"})
+		return
+	}
+	if len(decoded) > maxAssetSize {
+		c.JSON(http.StatusBadRequest, CreateErrors{File: "File is too large!"})
+		return
+	}
+	mimeType := http.DetectContentType(decoded)
+	if !allowedTypes[mimeType] {
+		c.JSON(http.StatusBadRequest, CreateErrors{File: "File type not allowed!"})
+		return
+	}
+
+	client, err := miniostorage.NewClient()
+	if err != nil {
+		log.Error("Error connecting to storage", err)
+		c.JSON(500, gin.H{"message": "Internal server error"})
+		return
+	}
+
+	objectName := utils.GenerateRandomFileName(payload.Kind+"_", "")
+	_, err = client.PutObject(
+		context.Background(), miniostorage.PrivateBucket(), objectName,
+		bytes.NewReader(decoded), int64(len(decoded)),
+		minio.PutObjectOptions{ContentType: mimeType},
+	)
+	if err != nil {
+		log.Error("Error uploading asset", err)
+		c.JSON(500, gin.H{"message": "Internal server error"})
+		return
+	}
+
+	asset := &models.Asset{
+		ObjectName: objectName,
+		Kind:       payload.Kind,
+		MimeType:   mimeType,
+		Size:       int64(len(decoded)),
+		OwnerId:    session.ID,
+		ChatId:     payload.ChatId,
+	}
+	err = db.DefaultClient.Create(asset).Error
+	if err != nil {
+		log.Error("Error saving asset", err)
+		if err := miniostorage.RemoveObject(miniostorage.PrivateBucket(), objectName); err != nil {
+			log.Error("Error removing asset object", err)
+		}
+		c.JSON(500, gin.H{"message": "Internal server error"})
+		return
+	}
+
+	result, err := signAsset(asset)
+	if err != nil {
+		log.Error("Error signing asset url", err)
+		c.JSON(500, gin.H{"message": "Internal server error"})
+		return
+	}
+
+	c.JSON(201, result)
+}
+
+func (h *AssetsRouter) findOne(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	asset, ok := findAllowed(uint(id), session.ID)
+	if !ok {
+		c.JSON(404, gin.H{"message": "Asset not found"})
+		return
+	}
+
+	result, err := signAsset(asset)
+	if err != nil {
+		log.Error("Error signing asset url", err)
+		c.JSON(500, gin.H{"message": "Internal server error"})
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+func (h *AssetsRouter) download(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	asset, ok := findAllowed(uint(id), session.ID)
+	if !ok {
+		c.JSON(404, gin.H{"message": "Asset not found"})
+		return
+	}
+
+	signed, err := miniostorage.PresignedURL(asset.ObjectName, URLExpiration)
+	if err != nil {
+		log.Error("Error signing asset url", err)
+		c.JSON(500, gin.H{"message": "Internal server error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusTemporaryRedirect, signed.String())
+}
+
+func (h *AssetsRouter) delete(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	asset := &models.Asset{}
+	err = db.DefaultClient.
+		Where("id = ? AND owner_id = ?", id, session.ID).
+		First(asset).Error
+	if err != nil {
+		c.JSON(404, gin.H{"message": "Asset not found"})
+		return
+	}
+
+	err = db.DefaultClient.Delete(asset).Error
+	if err != nil {
+		c.JSON(500, gin.H{"message": "Internal server error"})
+		return
+	}
+
+	// The object goes too, so URLs signed before stop working.
+	err = miniostorage.RemoveObject(miniostorage.PrivateBucket(), asset.ObjectName)
+	if err != nil {
+		log.Error("Error removing asset object", err)
+	}
+
+	c.JSON(200, gin.H{"message": "deleted"})
+}
+
+// findAllowed returns the asset when the user owns it or, for chat
+// attachments, when the user belongs to the chat it was shared in.
+func findAllowed(assetID, userID uint) (*models.Asset, bool) {
+	asset := &models.Asset{}
+	err := db.DefaultClient.
+		Where(&models.Asset{ID: assetID}).
+		First(asset).Error
+	if err != nil {
+		return nil, false
+	}
+
+	if asset.OwnerId == userID {
+		return asset, true
+	}
+	if asset.Kind == models.AssetKindChatAttachment && asset.ChatId != nil {
+		return asset, memberOfChat(*asset.ChatId, userID)
+	}
+
+	return nil, false
+}
+
+func memberOfChat(chatID, userID uint) bool {
+	chat := &models.Chat{}
+	err := db.DefaultClient.
+		Where(&models.Chat{ID: chatID}).
+		First(chat).Error
+	if err != nil {
+		return false
+	}
+	if chat.OwnerId == userID {
+		return true
+	}
+
+	count := int64(0)
+	db.DefaultClient.
+		Model(&models.ChatUser{}).
+		Where(&models.ChatUser{ChatId: chatID, UserId: userID}).
+		Count(&count)
+	return count > 0
+}
+
+func signAsset(asset *models.Asset) (*Asset, error) {
+	signed, err := miniostorage.PresignedURL(asset.ObjectName, URLExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Asset{
+		ID:         asset.ID,
+		Kind:       asset.Kind,
+		MimeType:   asset.MimeType,
+		Size:       asset.Size,
+		OwnerId:    asset.OwnerId,
+		ChatId:     asset.ChatId,
+		URL:        signed.String(),
+		ExpiresAt:  time.Now().Add(URLExpiration),
+		CreationAt: asset.CreationAt,
+	}, nil
+}
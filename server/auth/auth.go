@@ -116,7 +116,7 @@ type SignInPayload struct {
 }
 
 func (auth *AuthRouter) SignIn(c *gin.Context) {
-	fields := []string{"id", "first_name", "last_name", "username", "email", "photo_url", "phone", "password"}
+	fields := []string{"id", "first_name", "last_name", "username", "email", "photo_url", "phone", "rol", "password", "suspended_until"}
 
 	payload := &SignInPayload{}
 
@@ -147,6 +147,12 @@ func (auth *AuthRouter) SignIn(c *gin.Context) {
 		return
 	}
 	user.Password = ""
+	if user.SuspendedUntil != nil && user.SuspendedUntil.After(time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Account suspended",
+		})
+		return
+	}
 
 	session, err := utils.MakeSession(user)
 	if err != nil {
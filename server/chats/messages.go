@@ -0,0 +1,225 @@
+package chats
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/contentfilter"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/mentions"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/previews"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// editWindow is how long after sending a message its author can edit it.
+	editWindow = 15 * time.Minute
+
+	// deleteWindow is how long after sending a message its author can
+	// delete it for everyone, deleting it for oneself is always allowed.
+	deleteWindow = 24 * time.Hour
+
+	DeleteForMe       = "me"
+	DeleteForEveryone = "everyone"
+)
+
+type UpdateMessagePayload struct {
+	Content string `json:"content" validate:"required"`
+}
+
+// findMessage loads a message of the chat in the path, answering the
+// request when the user is not a member or there is no such message.
+func (h *ChatsRouter) findMessage(c *gin.Context, userID uint) (*models.Message, bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if ok, _ := h.memberOfChat(uint(id), userID); !ok {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	messageID, _ := strconv.Atoi(c.Param("messageId"))
+	message := &models.Message{}
+	err := db.DefaultClient.
+		Where(&models.Message{ID: uint(messageID), ChatId: uint(id)}).
+		Where("hidden = false").
+		First(message).Error
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Message not found"})
+		return nil, false
+	}
+	return message, true
+}
+
+// loadMessage reads a message back as the members see it.
+func loadMessage(messageID uint) (*Message, error) {
+	message := &Message{}
+	err := db.DefaultClient.
+		Model(&models.Message{}).
+		Unscoped().
+		Preload("User", "deleted_at is null").
+		Where("messages.id = ?", messageID).
+		First(message).Error
+	if err != nil {
+		return nil, err
+	}
+	hydrateMessages(message.ChatID, []*Message{message})
+	return message, nil
+}
+
+func (h *ChatsRouter) updateMessage(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := &UpdateMessagePayload{}
+	if err := c.ShouldBind(payload); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, CreateMessageErrors{
+			Content: errorsMap["Content"],
+		})
+		return
+	}
+
+	message, ok := h.findMessage(c, session.ID)
+	if !ok {
+		return
+	}
+	if message.UserId != session.ID {
+		c.JSON(403, gin.H{"error": "Forbidden"})
+		return
+	}
+	if time.Since(message.CreationAt) > editWindow {
+		c.JSON(403, gin.H{"error": "Edit window closed"})
+		return
+	}
+
+	filtered := contentfilter.CheckEdit(session.ID, contentfilter.SourceMessage, payload.Content)
+	if filtered.Rejected() {
+		c.JSON(http.StatusBadRequest, CreateMessageErrors{
+			Content: "Content not allowed!",
+		})
+		return
+	}
+
+	now := time.Now()
+	mentioned := []uint{}
+	pending := []uint{}
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(message).Updates(&models.Message{
+			Content:  filtered.Content,
+			Edited:   true,
+			EditedAt: &now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		pending, err = previews.Save(tx, models.MentionSourceMessage, message.ID, filtered.Content)
+		if err != nil {
+			return err
+		}
+		mentioned, err = mentions.Save(
+			tx, models.MentionSourceMessage, message.ID, filtered.Content,
+			chatMembersScope(message.ChatId),
+		)
+		return err
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+	previews.Fetch(pending)
+
+	updated, err := loadMessage(message.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+	broadcast(message.ChatId, "message_updated", updated)
+	mentions.Notify(session.ID, models.MentionSourceMessage, message.ID, mentioned)
+
+	c.JSON(200, updated)
+}
+
+// deleteMessage deletes a message for the user, or for everyone with
+// ?for=everyone. Messages deleted for everyone are kept as tombstones.
+func (h *ChatsRouter) deleteMessage(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	scope := c.DefaultQuery("for", DeleteForMe)
+	if scope != DeleteForMe && scope != DeleteForEveryone {
+		c.JSON(400, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	message, ok := h.findMessage(c, session.ID)
+	if !ok {
+		return
+	}
+
+	if scope == DeleteForMe {
+		err = db.DefaultClient.
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.MessageDeletion{MessageId: message.ID, UserId: session.ID}).Error
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Internal server error"})
+			return
+		}
+		c.JSON(200, gin.H{"message": "Deleted"})
+		return
+	}
+
+	if message.UserId != session.ID {
+		c.JSON(403, gin.H{"error": "Forbidden"})
+		return
+	}
+	if time.Since(message.CreationAt) > deleteWindow {
+		c.JSON(403, gin.H{"error": "Delete window closed"})
+		return
+	}
+
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(message).Error; err != nil {
+			return err
+		}
+		err := tx.Where(&models.Mention{SourceType: models.MentionSourceMessage, SourceId: message.ID}).
+			Delete(&models.Mention{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where(&models.Link{SourceType: models.MentionSourceMessage, SourceId: message.ID}).
+			Delete(&models.Link{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where(&models.MessageReaction{MessageId: message.ID}).
+			Delete(&models.MessageReaction{}).Error
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	broadcast(message.ChatId, "message_deleted", gin.H{
+		"chat_id":    message.ChatId,
+		"message_id": message.ID,
+	})
+
+	c.JSON(200, gin.H{"message": "Deleted"})
+}
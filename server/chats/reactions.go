@@ -0,0 +1,281 @@
+package chats
+
+import (
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm/clause"
+)
+
+type MessageReactionPayload struct {
+	Emoji string `json:"emoji" validate:"required,max=32"`
+}
+
+type MessageReactionErrors struct {
+	Emoji string `json:"emoji,omitempty"`
+}
+
+// MessageReactions aggregates the reactions of a message by emoji, in the
+// order each emoji was first used.
+type MessageReactions struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	UserIDs []uint `json:"user_ids"`
+}
+
+// emojiPresentation holds the pictographs shown as emoji by default.
+var emojiPresentation = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x231A, Hi: 0x231B, Stride: 1},
+		{Lo: 0x23E9, Hi: 0x23EC, Stride: 1},
+		{Lo: 0x23F0, Hi: 0x23F3, Stride: 3},
+		{Lo: 0x25FD, Hi: 0x25FE, Stride: 1},
+		{Lo: 0x2614, Hi: 0x2615, Stride: 1},
+		{Lo: 0x2648, Hi: 0x2653, Stride: 1},
+		{Lo: 0x267F, Hi: 0x2693, Stride: 20},
+		{Lo: 0x26A1, Hi: 0x26AA, Stride: 9},
+		{Lo: 0x26AB, Hi: 0x26AB, Stride: 1},
+		{Lo: 0x26BD, Hi: 0x26BE, Stride: 1},
+		{Lo: 0x26C4, Hi: 0x26C5, Stride: 1},
+		{Lo: 0x26CE, Hi: 0x26D4, Stride: 6},
+		{Lo: 0x26EA, Hi: 0x26EA, Stride: 1},
+		{Lo: 0x26F2, Hi: 0x26F3, Stride: 1},
+		{Lo: 0x26F5, Hi: 0x26FA, Stride: 5},
+		{Lo: 0x26FD, Hi: 0x2705, Stride: 8},
+		{Lo: 0x270A, Hi: 0x270B, Stride: 1},
+		{Lo: 0x2728, Hi: 0x274C, Stride: 36},
+		{Lo: 0x274E, Hi: 0x274E, Stride: 1},
+		{Lo: 0x2753, Hi: 0x2755, Stride: 1},
+		{Lo: 0x2757, Hi: 0x2757, Stride: 1},
+		{Lo: 0x2795, Hi: 0x2797, Stride: 1},
+		{Lo: 0x27B0, Hi: 0x27BF, Stride: 15},
+		{Lo: 0x2B1B, Hi: 0x2B1C, Stride: 1},
+		{Lo: 0x2B50, Hi: 0x2B55, Stride: 5},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1F004, Hi: 0x1F0CF, Stride: 203},
+		{Lo: 0x1F18E, Hi: 0x1F18E, Stride: 1},
+		{Lo: 0x1F191, Hi: 0x1F19A, Stride: 1},
+		{Lo: 0x1F1E6, Hi: 0x1F1FF, Stride: 1},
+		{Lo: 0x1F201, Hi: 0x1F21A, Stride: 25},
+		{Lo: 0x1F22F, Hi: 0x1F22F, Stride: 1},
+		{Lo: 0x1F232, Hi: 0x1F236, Stride: 1},
+		{Lo: 0x1F238, Hi: 0x1F23A, Stride: 1},
+		{Lo: 0x1F250, Hi: 0x1F251, Stride: 1},
+		{Lo: 0x1F300, Hi: 0x1F64F, Stride: 1},
+		{Lo: 0x1F680, Hi: 0x1F6FF, Stride: 1},
+		{Lo: 0x1F7E0, Hi: 0x1F7EB, Stride: 1},
+		{Lo: 0x1F90C, Hi: 0x1F9FF, Stride: 1},
+		{Lo: 0x1FA70, Hi: 0x1FAFF, Stride: 1},
+	},
+}
+
+// textPictographs holds the pictographs shown as text unless followed by
+// the emoji variation selector, like ©, ™ or the arrows.
+var textPictographs = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00A9, Hi: 0x00AE, Stride: 5},
+		{Lo: 0x203C, Hi: 0x2049, Stride: 13},
+		{Lo: 0x2122, Hi: 0x2139, Stride: 23},
+		{Lo: 0x2194, Hi: 0x2199, Stride: 1},
+		{Lo: 0x21A9, Hi: 0x21AA, Stride: 1},
+		{Lo: 0x2328, Hi: 0x23CF, Stride: 167},
+		{Lo: 0x23ED, Hi: 0x23EF, Stride: 1},
+		{Lo: 0x23F1, Hi: 0x23F2, Stride: 1},
+		{Lo: 0x23F8, Hi: 0x23FA, Stride: 1},
+		{Lo: 0x24C2, Hi: 0x24C2, Stride: 1},
+		{Lo: 0x25AA, Hi: 0x25AB, Stride: 1},
+		{Lo: 0x25B6, Hi: 0x25C0, Stride: 10},
+		{Lo: 0x25FB, Hi: 0x25FC, Stride: 1},
+		{Lo: 0x2600, Hi: 0x27BF, Stride: 1},
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1},
+		{Lo: 0x2B05, Hi: 0x2B07, Stride: 1},
+		{Lo: 0x3030, Hi: 0x303D, Stride: 13},
+		{Lo: 0x3297, Hi: 0x3299, Stride: 2},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1F170, Hi: 0x1F171, Stride: 1},
+		{Lo: 0x1F17E, Hi: 0x1F17F, Stride: 1},
+		{Lo: 0x1F202, Hi: 0x1F237, Stride: 53},
+		{Lo: 0x1F6CB, Hi: 0x1F6CB, Stride: 1},
+	},
+	LatinOffset: 1,
+}
+
+// isEmoji accepts a single emoji, including the sequences joined with ZWJ,
+// skin tone modifiers, flags and keycaps. Text pictographs count only with
+// the emoji variation selector, so symbols like © or ™ alone are refused.
+func isEmoji(value string) bool {
+	if value == "" || len(value) > 32 || !utf8.ValidString(value) {
+		return false
+	}
+	emoji, text, variation := false, false, false
+	for _, r := range value {
+		switch {
+		case unicode.Is(emojiPresentation, r), r == 0x20E3:
+			emoji = true
+		case unicode.Is(textPictographs, r):
+			text = true
+		case r == 0xFE0F:
+			variation = true
+		case r == 0x200D, r == 0xFE0E:
+		case r >= 0x1F3FB && r <= 0x1F3FF:
+		case r >= 0xE0020 && r <= 0xE007F:
+		case r == '#' || r == '*' || (r >= '0' && r <= '9'):
+		default:
+			return false
+		}
+	}
+	return emoji || (text && variation)
+}
+
+// findReactions aggregates the reactions of the given messages grouped by
+// message id.
+func findReactions(messageIDs []uint) map[uint][]MessageReactions {
+	result := map[uint][]MessageReactions{}
+	if len(messageIDs) == 0 {
+		return result
+	}
+
+	reactions := []models.MessageReaction{}
+	err := db.DefaultClient.
+		Select("message_id", "user_id", "emoji").
+		Where("message_id IN ?", messageIDs).
+		Order("id").
+		Find(&reactions).Error
+	if err != nil {
+		log.Error("Error loading message reactions", err)
+		return result
+	}
+
+	for _, reaction := range reactions {
+		groups := result[reaction.MessageId]
+		found := false
+		for i := range groups {
+			if groups[i].Emoji == reaction.Emoji {
+				groups[i].Count++
+				groups[i].UserIDs = append(groups[i].UserIDs, reaction.UserId)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, MessageReactions{
+				Emoji:   reaction.Emoji,
+				Count:   1,
+				UserIDs: []uint{reaction.UserId},
+			})
+		}
+		result[reaction.MessageId] = groups
+	}
+	return result
+}
+
+// broadcastReaction tells the members of the chat a reaction changed,
+// along with the new totals of the message.
+func broadcastReaction(message *models.Message, userID uint, emoji string, added bool) {
+	reactions := findReactions([]uint{message.ID})[message.ID]
+	if reactions == nil {
+		reactions = []MessageReactions{}
+	}
+	broadcast(message.ChatId, "message_reaction", gin.H{
+		"chat_id":    message.ChatId,
+		"message_id": message.ID,
+		"user_id":    userID,
+		"emoji":      emoji,
+		"added":      added,
+		"reactions":  reactions,
+	})
+}
+
+func (h *ChatsRouter) reactMessage(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := &MessageReactionPayload{}
+	if err := c.ShouldBind(payload); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, MessageReactionErrors{
+			Emoji: errorsMap["Emoji"],
+		})
+		return
+	}
+	if !isEmoji(payload.Emoji) {
+		c.JSON(http.StatusBadRequest, MessageReactionErrors{
+			Emoji: "Invalid field!",
+		})
+		return
+	}
+
+	message, ok := h.findMessage(c, session.ID)
+	if !ok {
+		return
+	}
+
+	result := db.DefaultClient.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.MessageReaction{
+			MessageId: message.ID,
+			UserId:    session.ID,
+			Emoji:     payload.Emoji,
+		})
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+	if result.RowsAffected > 0 {
+		broadcastReaction(message, session.ID, payload.Emoji, true)
+	}
+
+	c.JSON(200, gin.H{"message": "Reacted"})
+}
+
+// unreactMessage removes the reaction of the user with ?emoji.
+func (h *ChatsRouter) unreactMessage(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	emoji := c.Query("emoji")
+	if !isEmoji(emoji) {
+		c.JSON(http.StatusBadRequest, MessageReactionErrors{
+			Emoji: "Invalid field!",
+		})
+		return
+	}
+
+	message, ok := h.findMessage(c, session.ID)
+	if !ok {
+		return
+	}
+
+	result := db.DefaultClient.
+		Where(&models.MessageReaction{MessageId: message.ID, UserId: session.ID, Emoji: emoji}).
+		Delete(&models.MessageReaction{})
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+	if result.RowsAffected > 0 {
+		broadcastReaction(message, session.ID, emoji, false)
+	}
+
+	c.JSON(200, gin.H{"message": "Reaction removed"})
+}
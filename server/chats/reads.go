@@ -0,0 +1,165 @@
+package chats
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+)
+
+// maxReadByMembers is the largest chat whose messages list who read them,
+// bigger chats only get unread counts.
+const maxReadByMembers = 20
+
+type ReadPayload struct {
+	MessageID uint `json:"message_id"`
+}
+
+type ReadErrors struct {
+	MessageID string `json:"message_id,omitempty"`
+}
+
+// unreadCounts returns how many messages of others each chat of the user
+// has after the last one the user read. Thread replies are left out, they
+// are not in the timeline the read position moves along, and so are the
+// messages the user deleted for itself.
+func unreadCounts(userID uint) (map[uint]int64, error) {
+	rows := []struct {
+		ChatId uint
+		Unread int64
+	}{}
+	err := db.DefaultClient.
+		Model(&models.ChatUser{}).
+		Select("chat_users.chat_id, count(messages.id) AS unread").
+		Joins("JOIN messages ON messages.chat_id = chat_users.chat_id "+
+			"AND messages.id > COALESCE(chat_users.last_read_message_id, 0) "+
+			"AND messages.user_id <> chat_users.user_id "+
+			"AND messages.hidden = false AND messages.deleted_at IS NULL "+
+			"AND messages.thread_root_id IS NULL "+
+			"AND NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = messages.id AND d.user_id = chat_users.user_id)").
+		Where("chat_users.user_id = ?", userID).
+		Group("chat_users.chat_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	unread := map[uint]int64{}
+	for _, row := range rows {
+		unread[row.ChatId] = row.Unread
+	}
+	return unread, nil
+}
+
+// markRead moves the read position of a member forward to messageID, it
+// never moves back so late requests do not undo newer reads.
+func markRead(chatID, userID, messageID uint) (bool, error) {
+	result := db.DefaultClient.
+		Model(&models.ChatUser{}).
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		Where("last_read_message_id IS NULL OR last_read_message_id < ?", messageID).
+		Updates(map[string]interface{}{
+			"last_read_message_id": messageID,
+			"last_read_at":         time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// fillReadBy lists, in chats small enough, the members other than the
+// author that read up to each message.
+func fillReadBy(chatID uint, messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	members := []models.ChatUser{}
+	err := db.DefaultClient.
+		Select("user_id", "last_read_message_id").
+		Where(&models.ChatUser{ChatId: chatID}).
+		Limit(maxReadByMembers + 1).
+		Find(&members).Error
+	if err != nil || len(members) > maxReadByMembers {
+		return err
+	}
+
+	for _, message := range messages {
+		message.ReadBy = []uint{}
+		for _, member := range members {
+			if member.UserId == message.UserID || member.LastReadMessageId == nil {
+				continue
+			}
+			if *member.LastReadMessageId >= message.ID {
+				message.ReadBy = append(message.ReadBy, member.UserId)
+			}
+		}
+	}
+	return nil
+}
+
+// read marks the chat as read up to the given message, the newest one when
+// none is given, and tells the other members.
+func (h *ChatsRouter) read(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if ok, _ := h.memberOfChat(uint(id), session.ID); !ok {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// The body is optional, a bare request reads the whole chat.
+	payload := &ReadPayload{}
+	err = c.ShouldBind(payload)
+	if err != nil && err != io.EOF {
+		c.JSON(400, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	ids := []uint{}
+	tx := db.DefaultClient.
+		Model(&models.Message{}).
+		Where(&models.Message{ChatId: uint(id)})
+	if payload.MessageID != 0 {
+		tx = tx.Where("id = ?", payload.MessageID)
+	} else {
+		tx = tx.Order("id DESC").Limit(1)
+	}
+	if err := tx.Pluck("id", &ids).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+	if len(ids) == 0 {
+		if payload.MessageID != 0 {
+			c.JSON(http.StatusBadRequest, ReadErrors{
+				MessageID: "Invalid field!",
+			})
+			return
+		}
+		c.JSON(200, gin.H{"message": "Read"})
+		return
+	}
+
+	moved, err := markRead(uint(id), session.ID, ids[0])
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+	if moved {
+		broadcast(uint(id), "read", gin.H{
+			"chat_id":    id,
+			"user_id":    session.ID,
+			"message_id": ids[0],
+		})
+	}
+
+	c.JSON(200, gin.H{"message": "Read"})
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"time"
@@ -15,7 +15,10 @@ import (
 	"github.com/juliotorresmoreno/specialist-talk-api/db"
 	"github.com/juliotorresmoreno/specialist-talk-api/logger"
 	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/contentfilter"
 	"github.com/juliotorresmoreno/specialist-talk-api/server/events"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/mentions"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/previews"
 	"github.com/juliotorresmoreno/specialist-talk-api/utils"
 	"gorm.io/gorm"
 )
@@ -35,6 +38,14 @@ func SetupAPIRoutes(g *gin.RouterGroup) {
 	g.GET("/:id/users", h.getUsers)
 	g.GET("/:id/messages", h.getMessages)
 	g.POST("/:id/messages", h.createMessage)
+	g.PATCH("/:id/messages/:messageId", h.updateMessage)
+	g.DELETE("/:id/messages/:messageId", h.deleteMessage)
+	g.POST("/:id/messages/:messageId/reactions", h.reactMessage)
+	g.DELETE("/:id/messages/:messageId/reactions", h.unreactMessage)
+	g.GET("/:id/messages/:messageId/thread", h.getThread)
+	g.POST("/:id/read", h.read)
+	g.GET("/:id/typing", h.getTyping)
+	g.POST("/:id/typing", h.typing)
 }
 
 type User struct {
@@ -63,28 +74,29 @@ type Chat struct {
 	UserId      uint       `json:"user_id,omitempty" gorm:"-"`
 	Code        string     `json:"code"`
 	Active      bool       `json:"active"`
+	Unread      int64      `json:"unread" gorm:"-"`
 	CreationAt  time.Time  `json:"creation_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 }
 
 type ChatUser struct {
-	ID         uint       `json:"id"`
-	ChatId     uint       `json:"chat_id"`
-	Chat       Chat       `json:"-"`
-	UserId     uint       `json:"user_id"`
-	User       User       `json:"user"`
-	CreationAt time.Time  `json:"creation_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	ID                uint       `json:"id"`
+	ChatId            uint       `json:"chat_id"`
+	Chat              Chat       `json:"-"`
+	UserId            uint       `json:"user_id"`
+	User              User       `json:"user"`
+	LastReadMessageID *uint      `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at,omitempty"`
+	CreationAt        time.Time  `json:"creation_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	DeletedAt         *time.Time `json:"deleted_at,omitempty"`
 }
 
 func (h *ChatsRouter) find(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
@@ -102,10 +114,16 @@ func (h *ChatsRouter) find(c *gin.Context) {
 		return
 	}
 
+	unread, err := unreadCounts(session.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
 	chats := []Chat{}
 	for _, chatUser := range chatUsers {
 		if chatUser.Chat.Active {
-			json.NewEncoder(os.Stdout).Encode(chatUser.Chat)
+			chatUser.Chat.Unread = unread[chatUser.ChatId]
 			chats = append(chats, chatUser.Chat)
 		}
 	}
@@ -120,9 +138,7 @@ type CreateErrors struct {
 func (h *ChatsRouter) create(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
@@ -279,9 +295,11 @@ func parseChatUsers(chatUsers []models.ChatUser) []ChatUser {
 	result := []ChatUser{}
 	for _, chatUser := range chatUsers {
 		result = append(result, ChatUser{
-			ID:     chatUser.ID,
-			ChatId: chatUser.ChatId,
-			UserId: chatUser.UserId,
+			ID:                chatUser.ID,
+			ChatId:            chatUser.ChatId,
+			UserId:            chatUser.UserId,
+			LastReadMessageID: chatUser.LastReadMessageId,
+			LastReadAt:        chatUser.LastReadAt,
 			User: User{
 				ID:           chatUser.User.ID,
 				FirstName:    chatUser.User.FirstName,
@@ -303,9 +321,7 @@ func parseChatUsers(chatUsers []models.ChatUser) []ChatUser {
 func (h *ChatsRouter) update(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
@@ -359,9 +375,7 @@ type AddUserErrors struct {
 func (h *ChatsRouter) addUser(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
@@ -404,9 +418,7 @@ func (h *ChatsRouter) addUser(c *gin.Context) {
 func (h *ChatsRouter) findOne(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
@@ -423,9 +435,7 @@ func (h *ChatsRouter) findOne(c *gin.Context) {
 func (h *ChatsRouter) getUsers(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
@@ -449,23 +459,32 @@ func (h *ChatsRouter) getUsers(c *gin.Context) {
 }
 
 type Message struct {
-	ID         uint       `json:"id"`
-	ChatID     uint       `json:"chat_id"`
-	Chat       Chat       `json:"-"`
-	UserID     uint       `json:"user_id"`
-	User       User       `json:"user"`
-	Content    string     `json:"content"`
-	CreationAt time.Time  `json:"creation_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	ID               uint               `json:"id"`
+	ChatID           uint               `json:"chat_id"`
+	Chat             Chat               `json:"-"`
+	UserID           uint               `json:"user_id"`
+	User             User               `json:"user"`
+	Content          string             `json:"content"`
+	ReplyToMessageID *uint              `json:"reply_to_message_id,omitempty"`
+	ReplyTo          *QuotedMessage     `json:"reply_to,omitempty" gorm:"-"`
+	ThreadRootID     *uint              `json:"thread_root_id,omitempty"`
+	ReplyCount       int64              `json:"reply_count" gorm:"-"`
+	LastReplyAt      *time.Time         `json:"last_reply_at,omitempty" gorm:"-"`
+	Mentions         []mentions.Mention `json:"mentions" gorm:"-"`
+	Previews         []previews.Preview `json:"previews" gorm:"-"`
+	ReadBy           []uint             `json:"read_by,omitempty" gorm:"-"`
+	Reactions        []MessageReactions `json:"reactions" gorm:"-"`
+	Edited           bool               `json:"edited"`
+	EditedAt         *time.Time         `json:"edited_at,omitempty"`
+	CreationAt       time.Time          `json:"creation_at"`
+	UpdatedAt        time.Time          `json:"updated_at"`
+	DeletedAt        *time.Time         `json:"deleted_at,omitempty"`
 }
 
 func (h *ChatsRouter) getMessages(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
@@ -475,34 +494,134 @@ func (h *ChatsRouter) getMessages(c *gin.Context) {
 		return
 	}
 
+	// Thread messages are read apart, see getThread.
+	query := func() *gorm.DB {
+		return visibleMessages(uint(id), session.ID).
+			Where("messages.thread_root_id IS NULL")
+	}
+
+	// Pages are returned oldest first, the way a chat is read. ?around
+	// jumps to a message, loading the messages on both sides of it.
+	cursor := utils.ParseCursor(c)
 	messages := []*Message{}
-	err = db.DefaultClient.Model(&models.Message{}).
-		Where(&models.Message{ChatId: uint(id)}).
-		Preload("User", "deleted_at is null").
-		Find(&messages).Error
+	if around, _ := strconv.Atoi(c.Query("around")); around > 0 {
+		messages, err = messagesAround(query, uint(around), cursor.Limit)
+	} else {
+		err = cursor.Apply(query(), "messages.id").Find(&messages).Error
+		if !cursor.Reversed() {
+			slices.Reverse(messages)
+		}
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
+	hydrateMessages(uint(id), messages)
+
 	c.JSON(200, messages)
 }
 
+// visibleMessages scopes the messages of a chat the user can see. Messages
+// deleted for everyone stay as tombstones, the ones the user deleted for
+// itself are left out.
+func visibleMessages(chatID, userID uint) *gorm.DB {
+	return db.DefaultClient.Model(&models.Message{}).
+		Unscoped().
+		Where(&models.Message{ChatId: chatID}).
+		Where("messages.hidden = false").
+		Where("NOT EXISTS (SELECT 1 FROM message_deletions WHERE message_deletions.message_id = messages.id "+
+			"AND message_deletions.user_id = ?)", userID).
+		Preload("User", "deleted_at is null")
+}
+
+// hydrateMessages loads the data of the messages that does not come from
+// their rows, tombstones keep nothing of their content.
+func hydrateMessages(chatID uint, messages []*Message) {
+	ids := []uint{}
+	for _, message := range messages {
+		if message.DeletedAt != nil {
+			message.Content = ""
+			continue
+		}
+		ids = append(ids, message.ID)
+	}
+	messageMentions := mentions.Find(models.MentionSourceMessage, ids)
+	messagePreviews := previews.Find(models.MentionSourceMessage, ids)
+	messageReactions := findReactions(ids)
+	for _, message := range messages {
+		message.Mentions = messageMentions[message.ID]
+		message.Previews = messagePreviews[message.ID]
+		message.Reactions = messageReactions[message.ID]
+	}
+	if err := fillReadBy(chatID, messages); err != nil {
+		log.Error("Error loading read receipts", err)
+	}
+	if err := fillReplies(messages); err != nil {
+		log.Error("Error loading replies", err)
+	}
+}
+
+// messagesAround loads a window of limit messages centered on messageID,
+// the message itself included. Near either end of the chat the window
+// shifts so it is still filled.
+func messagesAround(query func() *gorm.DB, messageID uint, limit int) ([]*Message, error) {
+	older := []*Message{}
+	err := query().
+		Where("messages.id <= ?", messageID).
+		Order("messages.id DESC").
+		Limit(limit/2 + 1).
+		Find(&older).Error
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(older)
+
+	newer := []*Message{}
+	if remaining := limit - len(older); remaining > 0 {
+		err = query().
+			Where("messages.id > ?", messageID).
+			Order("messages.id ASC").
+			Limit(remaining).
+			Find(&newer).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(newer) < limit-len(older) && len(older) > 0 {
+		more := []*Message{}
+		err = query().
+			Where("messages.id < ?", older[0].ID).
+			Order("messages.id DESC").
+			Limit(limit - len(older) - len(newer)).
+			Find(&more).Error
+		if err != nil {
+			return nil, err
+		}
+		slices.Reverse(more)
+		older = append(more, older...)
+	}
+
+	return append(older, newer...), nil
+}
+
 type CreateMessagePayload struct {
-	Content string `json:"content" validate:"required"`
+	Content          string `json:"content" validate:"required"`
+	ReplyToMessageID *uint  `json:"reply_to_message_id"`
+	ThreadRootID     *uint  `json:"thread_root_id"`
 }
 
 type CreateMessageErrors struct {
-	Content string `json:"content,omitempty"`
+	Content          string `json:"content,omitempty"`
+	ReplyToMessageID string `json:"reply_to_message_id,omitempty"`
+	ThreadRootID     string `json:"thread_root_id,omitempty"`
 }
 
 func (h *ChatsRouter) createMessage(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
 		log.Error("Unauthorized: ", err)
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
@@ -529,6 +648,21 @@ func (h *ChatsRouter) createMessage(c *gin.Context) {
 		c.JSON(401, gin.H{"error": "Unauthorized"})
 		return
 	}
+
+	threadRootID, replyErrors := resolveReply(uint(id), payload)
+	if replyErrors != nil {
+		c.JSON(http.StatusBadRequest, replyErrors)
+		return
+	}
+
+	filtered := contentfilter.Check(session.ID, contentfilter.SourceMessage, payload.Content)
+	if filtered.Rejected() {
+		c.JSON(http.StatusBadRequest, CreateMessageErrors{
+			Content: "Content not allowed!",
+		})
+		return
+	}
+
 	if !chat.Active {
 		err := db.DefaultClient.Model(&models.Chat{}).
 			Where(&models.Chat{ID: uint(id)}).
@@ -543,32 +677,71 @@ func (h *ChatsRouter) createMessage(c *gin.Context) {
 	message := &models.Message{
 		ChatId:  uint(id),
 		UserId:  session.ID,
-		Content: payload.Content,
+		Content: filtered.Content,
+		Hidden:  filtered.Held(),
+
+		ReplyToMessageId: payload.ReplyToMessageID,
+		ThreadRootId:     threadRootID,
 	}
-	err = db.DefaultClient.Create(message).Error
+	pending := []uint{}
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		pending, err = previews.Save(tx, models.MentionSourceMessage, message.ID, message.Content)
+		if err != nil {
+			return err
+		}
+		_, err = mentions.Save(
+			tx, models.MentionSourceMessage, message.ID, message.Content,
+			chatMembersScope(uint(id)),
+		)
+		return err
+	})
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Internal server error"})
 		return
 	}
+	previews.Fetch(pending)
+	stopTyping(uint(id), session.ID)
+	if _, err := markRead(uint(id), session.ID, message.ID); err != nil {
+		log.Error("Error marking own message as read", err)
+	}
+
+	// Held messages reach the chat only if a moderator restores them.
+	if message.Hidden {
+		contentfilter.Hold(models.ModerationTargetMessage, message.ID, filtered.Rules)
+		c.JSON(202, gin.H{"message": "Held for review"})
+		return
+	}
 
-	h.sendToChat(uint(id), Message{
-		ID:     message.ID,
-		ChatID: message.ChatId,
-		UserID: message.UserId,
-		User: User{
-			ID:        session.ID,
-			FirstName: session.FirstName,
-			LastName:  session.LastName,
-			Username:  session.Username,
-			PhotoURL:  session.PhotoURL,
-		},
-		Content: message.Content,
-	})
+	AfterSend(message)
 
 	c.JSON(200, gin.H{"message": "Created"})
 }
 
-func (h *ChatsRouter) sendToChat(chatID uint, message Message) error {
+// AfterSend delivers a message that just became visible to the members of
+// its chat and notifies the users mentioned in it. Held messages go through
+// it once a moderator restores them.
+func AfterSend(message *models.Message) {
+	// The quoted message and thread fields come along with the rest.
+	created, err := loadMessage(message.ID)
+	if err != nil {
+		log.Error("Error loading created message", err)
+	} else if err := broadcast(message.ChatId, "message", *created); err != nil {
+		log.Error("Error sending message", err)
+	}
+
+	mentioned, err := mentions.Mentioned(models.MentionSourceMessage, message.ID)
+	if err != nil {
+		log.Error("Error loading message mentions", err)
+		return
+	}
+	mentions.Notify(message.UserId, models.MentionSourceMessage, message.ID, mentioned)
+}
+
+// broadcast sends an event to every member of a chat.
+func broadcast(chatID uint, eventType string, data interface{}) error {
 	chatUsers := []*ChatUser{}
 	err := db.DefaultClient.Model(&models.ChatUser{}).
 		Where(&models.ChatUser{ChatId: chatID}).
@@ -576,19 +749,23 @@ func (h *ChatsRouter) sendToChat(chatID uint, message Message) error {
 	if err != nil {
 		return err
 	}
-	data, _ := json.Marshal(message)
 	for _, chatUser := range chatUsers {
-		events.DefaultEventsRouter.Handler <- &events.Request{
-			ID: chatUser.UserId,
-			Event: &events.Event{
-				Type: "message",
-				Data: string(data),
-			},
-		}
+		events.DefaultEventsRouter.Send(chatUser.UserId, eventType, data)
 	}
 	return nil
 }
 
+// chatMembersScope restricts users to the members of a chat.
+func chatMembersScope(chatID uint) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(
+			"id IN (SELECT user_id FROM chat_users WHERE chat_id = ? AND deleted_at IS NULL) OR "+
+				"id IN (SELECT owner_id FROM chats WHERE id = ?)",
+			chatID, chatID,
+		)
+	}
+}
+
 func (h *ChatsRouter) memberOfChat(chatID, userID uint) (bool, *Chat) {
 	chat := &Chat{}
 	err := db.DefaultClient.Model(&models.Chat{}).
@@ -0,0 +1,200 @@
+package chats
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+)
+
+// snippetLength is how many characters of a quoted message are embedded in
+// the replies to it.
+const snippetLength = 140
+
+// QuotedMessage is the snippet of the message a reply points to. Deleted
+// and held messages are quoted with no content.
+type QuotedMessage struct {
+	ID      uint   `json:"id"`
+	UserID  uint   `json:"user_id,omitempty"`
+	User    *User  `json:"user,omitempty"`
+	Content string `json:"content"`
+	Deleted bool   `json:"deleted"`
+}
+
+func snippet(content string) string {
+	runes := []rune(content)
+	if len(runes) <= snippetLength {
+		return content
+	}
+	return string(runes[:snippetLength]) + "…"
+}
+
+// findChatMessage loads a message of the chat that is not held for review.
+func findChatMessage(chatID, messageID uint) (*models.Message, error) {
+	message := &models.Message{}
+	err := db.DefaultClient.
+		Where("id = ? AND chat_id = ?", messageID, chatID).
+		Where("hidden = false").
+		First(message).Error
+	return message, err
+}
+
+// resolveReply checks the message being replied to and the thread of a new
+// message, returning the thread it goes to. Replying to a thread message
+// places the reply in the same thread.
+func resolveReply(chatID uint, payload *CreateMessagePayload) (*uint, *CreateMessageErrors) {
+	threadRootID := payload.ThreadRootID
+	if threadRootID != nil {
+		root, err := findChatMessage(chatID, *threadRootID)
+		if err != nil || root.ThreadRootId != nil {
+			return nil, &CreateMessageErrors{ThreadRootID: "Invalid field!"}
+		}
+	}
+
+	if payload.ReplyToMessageID == nil {
+		return threadRootID, nil
+	}
+	quoted, err := findChatMessage(chatID, *payload.ReplyToMessageID)
+	if err != nil {
+		return nil, &CreateMessageErrors{ReplyToMessageID: "Invalid field!"}
+	}
+	if quoted.ThreadRootId != nil {
+		if threadRootID != nil && *threadRootID != *quoted.ThreadRootId {
+			return nil, &CreateMessageErrors{ReplyToMessageID: "Invalid field!"}
+		}
+		return quoted.ThreadRootId, nil
+	}
+	if threadRootID != nil && *threadRootID != quoted.ID {
+		return nil, &CreateMessageErrors{ReplyToMessageID: "Invalid field!"}
+	}
+	return threadRootID, nil
+}
+
+// fillReplies embeds the quoted messages of the replies and counts the
+// replies in the thread of each root message.
+func fillReplies(messages []*Message) error {
+	quotedIDs := []uint{}
+	rootIDs := []uint{}
+	for _, message := range messages {
+		if message.ReplyToMessageID != nil {
+			quotedIDs = append(quotedIDs, *message.ReplyToMessageID)
+		}
+		if message.ThreadRootID == nil {
+			rootIDs = append(rootIDs, message.ID)
+		}
+	}
+
+	if len(quotedIDs) > 0 {
+		quoted := []*Message{}
+		err := db.DefaultClient.
+			Model(&models.Message{}).
+			Unscoped().
+			Preload("User", "deleted_at is null").
+			Where("id IN ?", quotedIDs).
+			Where("hidden = false").
+			Find(&quoted).Error
+		if err != nil {
+			return err
+		}
+		byID := map[uint]*Message{}
+		for _, message := range quoted {
+			byID[message.ID] = message
+		}
+		for _, message := range messages {
+			if message.ReplyToMessageID == nil {
+				continue
+			}
+			message.ReplyTo = &QuotedMessage{ID: *message.ReplyToMessageID, Deleted: true}
+			original, ok := byID[*message.ReplyToMessageID]
+			if !ok {
+				continue
+			}
+			message.ReplyTo.UserID = original.UserID
+			message.ReplyTo.User = &original.User
+			if original.DeletedAt == nil {
+				message.ReplyTo.Content = snippet(original.Content)
+				message.ReplyTo.Deleted = false
+			}
+		}
+	}
+
+	if len(rootIDs) > 0 {
+		counts := []struct {
+			ThreadRootID uint
+			Replies      int64
+			LastReplyAt  time.Time
+		}{}
+		err := db.DefaultClient.
+			Model(&models.Message{}).
+			Select("thread_root_id, COUNT(*) AS replies, MAX(creation_at) AS last_reply_at").
+			Where("thread_root_id IN ?", rootIDs).
+			Where("hidden = false").
+			Group("thread_root_id").
+			Scan(&counts).Error
+		if err != nil {
+			return err
+		}
+		byRoot := map[uint]int{}
+		for i, count := range counts {
+			byRoot[count.ThreadRootID] = i
+		}
+		for _, message := range messages {
+			if i, ok := byRoot[message.ID]; ok {
+				message.ReplyCount = counts[i].Replies
+				message.LastReplyAt = &counts[i].LastReplyAt
+			}
+		}
+	}
+
+	return nil
+}
+
+// getThread pages through the side thread of a message, oldest first like
+// the main timeline. The root itself is not part of the page.
+func (h *ChatsRouter) getThread(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if ok, _ := h.memberOfChat(uint(id), session.ID); !ok {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// A root deleted for everyone keeps its thread readable.
+	rootID, _ := strconv.Atoi(c.Param("messageId"))
+	root := &Message{}
+	err = visibleMessages(uint(id), session.ID).
+		Where("messages.id = ?", rootID).
+		Where("messages.thread_root_id IS NULL").
+		First(root).Error
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Message not found"})
+		return
+	}
+
+	cursor := utils.ParseCursor(c)
+	messages := []*Message{}
+	err = cursor.Apply(
+		visibleMessages(uint(id), session.ID).Where("messages.thread_root_id = ?", root.ID),
+		"messages.id",
+	).Find(&messages).Error
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !cursor.Reversed() {
+		slices.Reverse(messages)
+	}
+
+	hydrateMessages(uint(id), messages)
+
+	c.JSON(200, messages)
+}
@@ -0,0 +1,124 @@
+package chats
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// typingTTL is how long a typing signal lasts, clients repeat it while the
+// user keeps typing.
+const typingTTL = 6 * time.Second
+
+type TypingPayload struct {
+	Typing *bool `json:"typing"`
+}
+
+// typingKey scores the members typing in a chat with the time their signal
+// expires, nothing about typing is ever stored in the database.
+func typingKey(chatID uint) string {
+	return "chat-typing-" + strconv.Itoa(int(chatID))
+}
+
+// typing starts or stops the typing signal of the user in a chat, a bare
+// request starts it.
+func (h *ChatsRouter) typing(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if ok, _ := h.memberOfChat(uint(id), session.ID); !ok {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	payload := &TypingPayload{}
+	err = c.ShouldBind(payload)
+	if err != nil && err != io.EOF {
+		c.JSON(400, gin.H{"error": "Invalid payload"})
+		return
+	}
+	typing := payload.Typing == nil || *payload.Typing
+
+	ctx := context.Background()
+	key := typingKey(uint(id))
+	member := strconv.Itoa(int(session.ID))
+	pipe := db.DefaultCache.TxPipeline()
+	if typing {
+		now := time.Now()
+		expires := now.Add(typingTTL)
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(expires.UnixMilli()), Member: member})
+		pipe.Expire(ctx, key, typingTTL)
+	} else {
+		pipe.ZRem(ctx, key, member)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Error("Error saving typing signal", err)
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	broadcast(uint(id), "typing", gin.H{
+		"chat_id":    id,
+		"user_id":    session.ID,
+		"typing":     typing,
+		"expires_in": typingTTL.Milliseconds(),
+	})
+
+	c.JSON(200, gin.H{"typing": typing})
+}
+
+// stopTyping clears the typing signal of a user that sent a message,
+// clients drop the indicator when the message arrives.
+func stopTyping(chatID, userID uint) {
+	err := db.DefaultCache.ZRem(context.Background(), typingKey(chatID), strconv.Itoa(int(userID))).Err()
+	if err != nil {
+		log.Error("Error clearing typing signal", err)
+	}
+}
+
+// getTyping returns the members typing right now, for clients that join
+// while others are typing.
+func (h *ChatsRouter) getTyping(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if ok, _ := h.memberOfChat(uint(id), session.ID); !ok {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	members, err := db.DefaultCache.ZRangeByScore(context.Background(), typingKey(uint(id)), &redis.ZRangeBy{
+		Min: strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		log.Error("Error loading typing signals", err)
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	userIDs := []uint{}
+	for _, member := range members {
+		userID, _ := strconv.Atoi(member)
+		if uint(userID) != session.ID {
+			userIDs = append(userIDs, uint(userID))
+		}
+	}
+
+	c.JSON(200, gin.H{"user_ids": userIDs})
+}
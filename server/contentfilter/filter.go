@@ -0,0 +1,175 @@
+package contentfilter
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/logger"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+)
+
+var log = logger.SetupLogger()
+
+// Actions a rule can take, ordered from the mildest to the strongest. The
+// strongest action of the rules that matched is the one applied.
+const (
+	ActionAllow  = "allow"
+	ActionMask   = "mask"
+	ActionHold   = "hold"
+	ActionReject = "reject"
+)
+
+var severity = map[string]int{
+	ActionAllow:  0,
+	ActionMask:   1,
+	ActionHold:   2,
+	ActionReject: 3,
+}
+
+// Sources of the content being checked.
+const (
+	SourcePost    = "post"
+	SourceComment = "comment"
+	SourceMessage = "message"
+)
+
+const statsKey = "content-filter-stats"
+
+// Input is a piece of content about to be written, Edit tells it replaces
+// content written before.
+type Input struct {
+	UserID  uint
+	Source  string
+	Content string
+	Edit    bool
+}
+
+// Rule inspects an input and decides what to do with it, rules that mask
+// return the content to store instead.
+type Rule interface {
+	Name() string
+	Check(input *Input) (action string, content string)
+}
+
+// Result is the outcome of running a pipeline, Content has every mask
+// applied and Rules lists the rules that matched.
+type Result struct {
+	Action  string
+	Content string
+	Rules   []string
+}
+
+func (r *Result) Rejected() bool {
+	return r.Action == ActionReject
+}
+
+func (r *Result) Held() bool {
+	return r.Action == ActionHold
+}
+
+// Pipeline runs its rules in order, Record is told of every rule that
+// matched.
+type Pipeline struct {
+	Rules  []Rule
+	Record func(rule, action string)
+}
+
+// Run passes the content through every rule, masks are applied in order so
+// later rules see the masked content. A rejection stops the pipeline.
+// Edited content is never held, as it would stay visible while waiting for
+// review, it is rejected instead.
+func (p *Pipeline) Run(input Input) *Result {
+	result := &Result{Action: ActionAllow, Content: input.Content, Rules: []string{}}
+	for _, rule := range p.Rules {
+		action, content := rule.Check(&input)
+		if action == ActionAllow {
+			continue
+		}
+		if action == ActionMask {
+			input.Content = content
+			result.Content = content
+		}
+		result.Rules = append(result.Rules, rule.Name())
+		if severity[action] > severity[result.Action] {
+			result.Action = action
+		}
+		if p.Record != nil {
+			p.Record(rule.Name(), action)
+		}
+		if action == ActionReject {
+			break
+		}
+	}
+	if input.Edit && result.Action == ActionHold {
+		result.Action = ActionReject
+	}
+	return result
+}
+
+func record(rule, action string) {
+	err := db.DefaultCache.HIncrBy(context.Background(), statsKey, rule+":"+action, 1).Err()
+	if err != nil {
+		log.Error("Error recording content filter stats", err)
+	}
+}
+
+// Stats returns how many times each rule took each action.
+func Stats() (map[string]map[string]int64, error) {
+	values, err := db.DefaultCache.HGetAll(context.Background(), statsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]map[string]int64{}
+	for field, value := range values {
+		rule, action, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		if _, ok := stats[rule]; !ok {
+			stats[rule] = map[string]int64{}
+		}
+		stats[rule][action], _ = strconv.ParseInt(value, 10, 64)
+	}
+	return stats, nil
+}
+
+// Hold opens a report without reporter for content the filter held back,
+// moderators restore or remove it from the queue.
+func Hold(targetType string, targetID uint, rules []string) {
+	err := db.DefaultClient.Create(&models.Report{
+		TargetType: targetType,
+		TargetId:   targetID,
+		Reason:     models.ReportReasonAutomated,
+		Details:    strings.Join(rules, ", "),
+	}).Error
+	if err != nil {
+		log.Error("Error holding content for review", err)
+	}
+}
+
+var (
+	defaultPipeline *Pipeline
+	setupOnce       sync.Once
+)
+
+func pipeline() *Pipeline {
+	setupOnce.Do(func() {
+		defaultPipeline = NewPipeline(ConfigFromEnv())
+	})
+	return defaultPipeline
+}
+
+// Check runs the default pipeline, configured from the environment.
+func Check(userID uint, source, content string) *Result {
+	return pipeline().Run(Input{UserID: userID, Source: source, Content: content})
+}
+
+// CheckEdit runs the default pipeline on an edit of content written
+// before, it can only be allowed, masked or rejected.
+func CheckEdit(userID uint, source, content string) *Result {
+	return pipeline().Run(Input{UserID: userID, Source: source, Content: content, Edit: true})
+}
@@ -0,0 +1,177 @@
+package contentfilter
+
+import (
+	"slices"
+	"testing"
+)
+
+// fixedRule takes the same action on every input, masking rules replace
+// the whole content.
+type fixedRule struct {
+	name   string
+	action string
+	mask   string
+}
+
+func (r *fixedRule) Name() string {
+	return r.name
+}
+
+func (r *fixedRule) Check(input *Input) (string, string) {
+	if r.action == ActionMask {
+		return ActionMask, r.mask
+	}
+	return r.action, input.Content
+}
+
+func TestWordsRuleMask(t *testing.T) {
+	rule := NewWordsRule("masked_words", ActionMask, []string{"malo", "feo", "c++"})
+	cases := []struct {
+		content string
+		action  string
+		want    string
+	}{
+		{"nada que ver", ActionAllow, "nada que ver"},
+		{"malo", ActionMask, "****"},
+		{"MALO y Feo", ActionMask, "**** y ***"},
+		{"malo malo malo", ActionMask, "**** **** ****"},
+		{"¡malo!, (feo).", ActionMask, "¡****!, (***)."},
+		{"el niño malo", ActionMask, "el niño ****"},
+		{"maléfico malo", ActionMask, "maléfico ****"},
+		{"maló malo", ActionMask, "maló ****"},
+		{"ñmalo malo", ActionMask, "ñmalo ****"},
+		{"malos feos", ActionAllow, "malos feos"},
+		{"feo_malo", ActionAllow, "feo_malo"},
+		{"malo2", ActionAllow, "malo2"},
+		{"sabe c++ bien", ActionMask, "sabe *** bien"},
+		{"日本malo", ActionAllow, "日本malo"},
+		{"日本 malo", ActionMask, "日本 ****"},
+	}
+
+	for _, tc := range cases {
+		action, content := rule.Check(&Input{Content: tc.content})
+		if action != tc.action || content != tc.want {
+			t.Errorf("%q: got %s %q, want %s %q", tc.content, action, content, tc.action, tc.want)
+		}
+	}
+}
+
+func TestWordsRuleAccentedWords(t *testing.T) {
+	rule := NewWordsRule("blocked_words", ActionReject, []string{"canción", "añejo"})
+	cases := []struct {
+		content string
+		action  string
+	}{
+		{"una canción", ActionReject},
+		{"CANCIÓN", ActionReject},
+		{"canciónes", ActionAllow},
+		{"añejo.", ActionReject},
+		{"añejos", ActionAllow},
+	}
+
+	for _, tc := range cases {
+		if action, _ := rule.Check(&Input{Content: tc.content}); action != tc.action {
+			t.Errorf("%q: got %s, want %s", tc.content, action, tc.action)
+		}
+	}
+}
+
+func TestLinksRule(t *testing.T) {
+	rule := &LinksRule{MaxLinks: 2, BlockedDomains: []string{"spam.com"}}
+	cases := []struct {
+		content string
+		action  string
+	}{
+		{"see https://example.com", ActionAllow},
+		{"see https://spam.com/offer", ActionReject},
+		{"see www.deals.spam.com", ActionReject},
+		{"see https://notspam.com", ActionAllow},
+		{"a.com http://a.com http://b.com", ActionAllow},
+		{"http://a.com http://b.com http://c.com", ActionHold},
+		{"http://a.com http://b.com http://spam.com", ActionReject},
+	}
+
+	for _, tc := range cases {
+		if action, _ := rule.Check(&Input{Content: tc.content}); action != tc.action {
+			t.Errorf("%q: got %s, want %s", tc.content, action, tc.action)
+		}
+	}
+}
+
+func TestPipelineAction(t *testing.T) {
+	allow := &fixedRule{name: "allow", action: ActionAllow}
+	mask := &fixedRule{name: "mask", action: ActionMask, mask: "masked"}
+	hold := &fixedRule{name: "hold", action: ActionHold}
+	reject := &fixedRule{name: "reject", action: ActionReject}
+
+	cases := []struct {
+		name    string
+		rules   []Rule
+		edit    bool
+		action  string
+		content string
+		matched []string
+	}{
+		{"nothing matches", []Rule{allow, allow}, false, ActionAllow, "original", []string{}},
+		{"mask", []Rule{allow, mask}, false, ActionMask, "masked", []string{"mask"}},
+		{"hold wins over mask", []Rule{mask, hold}, false, ActionHold, "masked", []string{"mask", "hold"}},
+		{"hold wins in any order", []Rule{hold, mask}, false, ActionHold, "masked", []string{"hold", "mask"}},
+		{"reject wins", []Rule{mask, reject, hold}, false, ActionReject, "masked", []string{"mask", "reject"}},
+		{"reject stops the pipeline", []Rule{reject, mask}, false, ActionReject, "original", []string{"reject"}},
+		{"edits are not held", []Rule{mask, hold}, true, ActionReject, "masked", []string{"mask", "hold"}},
+		{"edits are masked", []Rule{mask}, true, ActionMask, "masked", []string{"mask"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorded := []string{}
+			pipeline := &Pipeline{Rules: tc.rules, Record: func(rule, action string) {
+				recorded = append(recorded, rule)
+			}}
+			result := pipeline.Run(Input{Content: "original", Edit: tc.edit})
+			if result.Action != tc.action || result.Content != tc.content {
+				t.Errorf("got %s %q, want %s %q", result.Action, result.Content, tc.action, tc.content)
+			}
+			if !slices.Equal(result.Rules, tc.matched) || !slices.Equal(recorded, tc.matched) {
+				t.Errorf("got rules %v recorded %v, want %v", result.Rules, recorded, tc.matched)
+			}
+		})
+	}
+}
+
+func TestPipelineMasksInOrder(t *testing.T) {
+	pipeline := &Pipeline{Rules: []Rule{
+		NewWordsRule("first", ActionMask, []string{"malo"}),
+		NewWordsRule("second", ActionHold, []string{"malo", "feo"}),
+	}}
+
+	result := pipeline.Run(Input{Content: "malo"})
+	if result.Action != ActionMask || result.Content != "****" {
+		t.Errorf("got %s %q, later rules must see the masked content", result.Action, result.Content)
+	}
+
+	result = pipeline.Run(Input{Content: "malo y feo"})
+	if result.Action != ActionHold || result.Content != "**** y feo" {
+		t.Errorf("got %s %q", result.Action, result.Content)
+	}
+}
+
+func TestRepetitionRule(t *testing.T) {
+	cases := []struct {
+		content string
+		action  string
+	}{
+		{"a perfectly normal sentence", ActionAllow},
+		{"noooooooooooooooooooooo", ActionHold},
+		{"spaces                         between", ActionAllow},
+		{"buy buy buy buy buy buy now please do it", ActionHold},
+		{"one two three four five six seven eight nine ten", ActionAllow},
+	}
+
+	rule := &RepetitionRule{}
+	for _, tc := range cases {
+		if action, _ := rule.Check(&Input{Content: tc.content}); action != tc.action {
+			t.Errorf("%q: got %s, want %s", tc.content, action, tc.action)
+		}
+	}
+}
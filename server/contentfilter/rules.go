@@ -0,0 +1,311 @@
+package contentfilter
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/redis/go-redis/v9"
+)
+
+// Config holds the lists and thresholds of the default rules, every value
+// can be set from the environment.
+type Config struct {
+	BlockedWords   []string
+	HeldWords      []string
+	MaskedWords    []string
+	BlockedDomains []string
+	MaxLinks       int
+	MaxRepeats     int
+	RateLimit      int
+	ChatRateLimit  int
+}
+
+// ConfigFromEnv reads the comma separated CONTENT_FILTER_* lists and
+// numbers, missing thresholds fall back to sensible defaults.
+func ConfigFromEnv() Config {
+	return Config{
+		BlockedWords:   envList("CONTENT_FILTER_BLOCKED_WORDS"),
+		HeldWords:      envList("CONTENT_FILTER_HELD_WORDS"),
+		MaskedWords:    envList("CONTENT_FILTER_MASKED_WORDS"),
+		BlockedDomains: envList("CONTENT_FILTER_BLOCKED_DOMAINS"),
+		MaxLinks:       envInt("CONTENT_FILTER_MAX_LINKS", 3),
+		MaxRepeats:     envInt("CONTENT_FILTER_MAX_REPEATS", 2),
+		RateLimit:      envInt("CONTENT_FILTER_RATE_LIMIT", 10),
+		ChatRateLimit:  envInt("CONTENT_FILTER_CHAT_RATE_LIMIT", 60),
+	}
+}
+
+func envList(name string) []string {
+	values := []string{}
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func NewPipeline(config Config) *Pipeline {
+	rules := []Rule{&RateRule{
+		Limit:  config.RateLimit,
+		Limits: map[string]int{SourceMessage: config.ChatRateLimit},
+		Window: time.Minute,
+	}}
+	if len(config.BlockedWords) > 0 {
+		rules = append(rules, NewWordsRule("blocked_words", ActionReject, config.BlockedWords))
+	}
+	if len(config.HeldWords) > 0 {
+		rules = append(rules, NewWordsRule("held_words", ActionHold, config.HeldWords))
+	}
+	if len(config.MaskedWords) > 0 {
+		rules = append(rules, NewWordsRule("masked_words", ActionMask, config.MaskedWords))
+	}
+	rules = append(rules,
+		&LinksRule{MaxLinks: config.MaxLinks, BlockedDomains: config.BlockedDomains},
+		&RepetitionRule{},
+		&DuplicateRule{MaxRepeats: config.MaxRepeats, MinLength: 20, Window: 10 * time.Minute},
+	)
+	return &Pipeline{Rules: rules, Record: record}
+}
+
+// WordsRule matches whole words of a list regardless of case, masking
+// rules replace every letter of the word with an asterisk.
+type WordsRule struct {
+	name    string
+	action  string
+	pattern *regexp.Regexp
+}
+
+// wordBoundary stands in for \b, which only knows ASCII letters and would
+// split words ending in accented ones.
+const wordBoundary = `[^\p{L}\p{M}\p{N}_]`
+
+func NewWordsRule(name, action string, words []string) *WordsRule {
+	quoted := []string{}
+	for _, word := range words {
+		quoted = append(quoted, regexp.QuoteMeta(word))
+	}
+	return &WordsRule{
+		name:   name,
+		action: action,
+		pattern: regexp.MustCompile(
+			`(?i)(?:^|` + wordBoundary + `)(` + strings.Join(quoted, "|") + `)(?:$|` + wordBoundary + `)`,
+		),
+	}
+}
+
+func (r *WordsRule) Name() string {
+	return r.name
+}
+
+// matches returns the byte ranges of the listed words in content. Every
+// search resumes right after the last word, so the boundary following it
+// can open the next one.
+func (r *WordsRule) matches(content string) [][2]int {
+	found := [][2]int{}
+	for offset := 0; offset < len(content); {
+		loc := r.pattern.FindStringSubmatchIndex(content[offset:])
+		if loc == nil {
+			break
+		}
+		found = append(found, [2]int{offset + loc[2], offset + loc[3]})
+		offset += loc[3]
+	}
+	return found
+}
+
+func (r *WordsRule) Check(input *Input) (string, string) {
+	found := r.matches(input.Content)
+	if len(found) == 0 {
+		return ActionAllow, input.Content
+	}
+	if r.action != ActionMask {
+		return r.action, input.Content
+	}
+
+	masked := strings.Builder{}
+	last := 0
+	for _, word := range found {
+		masked.WriteString(input.Content[last:word[0]])
+		masked.WriteString(strings.Repeat("*", utf8.RuneCountInString(input.Content[word[0]:word[1]])))
+		last = word[1]
+	}
+	masked.WriteString(input.Content[last:])
+	return ActionMask, masked.String()
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s]+|\bwww\.[^\s]+`)
+
+// LinksRule rejects links to blocked domains and holds content with more
+// links than allowed, a common trait of spam.
+type LinksRule struct {
+	MaxLinks       int
+	BlockedDomains []string
+}
+
+func (r *LinksRule) Name() string {
+	return "links"
+}
+
+func (r *LinksRule) Check(input *Input) (string, string) {
+	links := linkPattern.FindAllString(input.Content, -1)
+	for _, link := range links {
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		parsed, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(parsed.Hostname())
+		for _, domain := range r.BlockedDomains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return ActionReject, input.Content
+			}
+		}
+	}
+	if len(links) > r.MaxLinks {
+		return ActionHold, input.Content
+	}
+	return ActionAllow, input.Content
+}
+
+// RepetitionRule holds content made of a long run of the same character
+// or dominated by a single repeated word.
+type RepetitionRule struct{}
+
+func (r *RepetitionRule) Name() string {
+	return "repetition"
+}
+
+func (r *RepetitionRule) Check(input *Input) (string, string) {
+	run, last := 0, rune(0)
+	for _, char := range input.Content {
+		if char == last {
+			run++
+		} else {
+			run, last = 1, char
+		}
+		if run >= 20 && char != ' ' {
+			return ActionHold, input.Content
+		}
+	}
+
+	words := strings.Fields(strings.ToLower(input.Content))
+	if len(words) < 10 {
+		return ActionAllow, input.Content
+	}
+	counts := map[string]int{}
+	for _, word := range words {
+		counts[word]++
+		if counts[word]*2 > len(words) {
+			return ActionHold, input.Content
+		}
+	}
+	return ActionAllow, input.Content
+}
+
+// DuplicateRule rejects the same content written by a user to the same
+// source more than MaxRepeats times within Window. Content shorter than
+// MinLength, like a quick "ok" in a chat, and edits, which may save the
+// same text again, are not tracked.
+type DuplicateRule struct {
+	MaxRepeats int
+	MinLength  int
+	Window     time.Duration
+}
+
+func (r *DuplicateRule) Name() string {
+	return "duplicate"
+}
+
+func (r *DuplicateRule) Check(input *Input) (string, string) {
+	if input.Edit {
+		return ActionAllow, input.Content
+	}
+	normalized := strings.ToLower(strings.Join(strings.Fields(input.Content), " "))
+	if utf8.RuneCountInString(normalized) < r.MinLength {
+		return ActionAllow, input.Content
+	}
+	sum := sha1.Sum([]byte(normalized))
+	key := "content-filter-duplicate-" + input.Source + "-" + strconv.Itoa(int(input.UserID)) + "-" + hex.EncodeToString(sum[:])
+
+	count, err := incrWithin(key, r.Window)
+	if err != nil {
+		log.Error("Error checking duplicate content", err)
+		return ActionAllow, input.Content
+	}
+	if count > int64(r.MaxRepeats) {
+		return ActionReject, input.Content
+	}
+	return ActionAllow, input.Content
+}
+
+// RateRule rejects writes of a user past Limit per Window for each source,
+// Limits overrides it for the sources written to more often, like chats.
+// Edits are counted apart, fixing a typo does not use up new writes.
+type RateRule struct {
+	Limit  int
+	Limits map[string]int
+	Window time.Duration
+}
+
+func (r *RateRule) Name() string {
+	return "rate"
+}
+
+func (r *RateRule) Check(input *Input) (string, string) {
+	source := input.Source
+	if input.Edit {
+		source += "-edit"
+	}
+	key := "content-filter-rate-" + source + "-" + strconv.Itoa(int(input.UserID))
+
+	count, err := incrWithin(key, r.Window)
+	if err != nil {
+		log.Error("Error checking write rate", err)
+		return ActionAllow, input.Content
+	}
+	limit := r.Limit
+	if sourceLimit, ok := r.Limits[input.Source]; ok {
+		limit = sourceLimit
+	}
+	if count > int64(limit) {
+		return ActionReject, input.Content
+	}
+	return ActionAllow, input.Content
+}
+
+// incrScript sets the expiration along with the first hit, so a key never
+// outlives its window.
+var incrScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// incrWithin counts hits of key in a fixed window starting on the first
+// hit.
+func incrWithin(key string, window time.Duration) (int64, error) {
+	return incrScript.Run(context.Background(), db.DefaultCache, []string{key}, window.Milliseconds()).Int64()
+}
@@ -0,0 +1,300 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+const (
+	// presenceKey scores every connected user with the time of its last
+	// heartbeat, users whose heartbeat is older than presenceTimeout are
+	// offline even if their instance died without disconnecting them.
+	presenceKey            = "presence"
+	presenceConnectionsKey = "presence-connections"
+	presenceStatusKey      = "presence-status"
+
+	presenceHeartbeat = 30 * time.Second
+	presenceTimeout   = 90 * time.Second
+
+	maxPresenceUsers = 100
+)
+
+type Presence struct {
+	UserID     uint       `json:"user_id"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+type PresencePayload struct {
+	Status string `json:"status" validate:"required,oneof=online away"`
+}
+
+type PresenceErrors struct {
+	Status string `json:"status,omitempty"`
+}
+
+func heartbeatAlive(score float64) bool {
+	return time.Since(time.Unix(int64(score), 0)) < presenceTimeout
+}
+
+// connectScript counts a connection and refreshes the heartbeat in one
+// step, returning whether the user was online already. Connections counted
+// by an instance that died, whose heartbeat expired, are dropped.
+var connectScript = redis.NewScript(`
+local score = redis.call("ZSCORE", KEYS[1], ARGV[1])
+local online = score and tonumber(ARGV[2]) - tonumber(score) < tonumber(ARGV[3])
+if online then
+	redis.call("HINCRBY", KEYS[2], ARGV[1], 1)
+else
+	redis.call("HSET", KEYS[2], ARGV[1], 1)
+	redis.call("HDEL", KEYS[3], ARGV[1])
+end
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+if online then
+	return 1
+end
+return 0
+`)
+
+// disconnectScript closes a connection, the last one removes every trace
+// of the user. It returns the connections left.
+var disconnectScript = redis.NewScript(`
+local left = redis.call("HINCRBY", KEYS[2], ARGV[1], -1)
+if left <= 0 then
+	redis.call("HDEL", KEYS[2], ARGV[1])
+	redis.call("HDEL", KEYS[3], ARGV[1])
+	redis.call("ZREM", KEYS[1], ARGV[1])
+end
+return left
+`)
+
+var presenceKeys = []string{presenceKey, presenceConnectionsKey, presenceStatusKey}
+
+// connect counts a new SSE connection of the user, the first one across
+// every instance brings the user online.
+func (h *EventsRouter) connect(userID uint) {
+	online, err := connectScript.Run(
+		context.Background(), db.DefaultCache, presenceKeys,
+		strconv.Itoa(int(userID)), time.Now().Unix(), int64(presenceTimeout.Seconds()),
+	).Bool()
+	if err != nil {
+		log.Error("Error tracking presence", err)
+		return
+	}
+	if !online {
+		go h.notifyPresence(userID, PresenceOnline)
+	}
+}
+
+func (h *EventsRouter) heartbeat(userID uint) {
+	err := db.DefaultCache.ZAdd(context.Background(), presenceKey, redis.Z{
+		Score:  float64(time.Now().Unix()),
+		Member: strconv.Itoa(int(userID)),
+	}).Err()
+	if err != nil {
+		log.Error("Error tracking presence", err)
+	}
+}
+
+// disconnect closes a connection of the user, the last one takes the user
+// offline and records when it was last seen.
+func (h *EventsRouter) disconnect(userID uint) {
+	left, err := disconnectScript.Run(
+		context.Background(), db.DefaultCache, presenceKeys, strconv.Itoa(int(userID)),
+	).Int64()
+	if err != nil {
+		log.Error("Error tracking presence", err)
+		return
+	}
+	if left > 0 {
+		return
+	}
+
+	err = db.DefaultClient.
+		Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("last_seen_at", time.Now()).Error
+	if err != nil {
+		log.Error("Error saving last seen", err)
+	}
+	go h.notifyPresence(userID, PresenceOffline)
+}
+
+// notifyPresence tells the members of the user's chats that its status
+// changed, unless the user hides its presence. It runs apart from the SSE
+// loops since delivering events waits on them.
+func (h *EventsRouter) notifyPresence(userID uint, status string) {
+	recipients := []uint{}
+	err := db.DefaultClient.
+		Model(&models.ChatUser{}).
+		Where("chat_id IN (SELECT chat_id FROM chat_users WHERE user_id = ? AND deleted_at IS NULL)", userID).
+		Where("user_id <> ?", userID).
+		Where("EXISTS (SELECT 1 FROM users WHERE users.id = ? AND users.show_presence)", userID).
+		Distinct().
+		Pluck("user_id", &recipients).Error
+	if err != nil {
+		log.Error("Error loading presence recipients", err)
+		return
+	}
+
+	presence := Presence{UserID: userID, Status: status}
+	if status == PresenceOffline {
+		now := time.Now()
+		presence.LastSeenAt = &now
+	}
+	for _, recipient := range recipients {
+		h.Send(recipient, "presence", presence)
+	}
+}
+
+// Presences returns the status of the given users as seen by the viewer,
+// users hiding their presence always look offline.
+func Presences(viewerID uint, userIDs []uint) ([]Presence, error) {
+	users := []models.User{}
+	err := db.DefaultClient.
+		Select("id", "show_presence", "last_seen_at").
+		Where("id IN ?", userIDs).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	pipe := db.DefaultCache.Pipeline()
+	scores := map[uint]*redis.FloatCmd{}
+	statuses := map[uint]*redis.StringCmd{}
+	for _, user := range users {
+		member := strconv.Itoa(int(user.ID))
+		scores[user.ID] = pipe.ZScore(ctx, presenceKey, member)
+		statuses[user.ID] = pipe.HGet(ctx, presenceStatusKey, member)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	result := []Presence{}
+	for _, user := range users {
+		presence := Presence{UserID: user.ID, Status: PresenceOffline}
+		if !user.ShowPresence && user.ID != viewerID {
+			result = append(result, presence)
+			continue
+		}
+
+		presence.LastSeenAt = user.LastSeenAt
+		score, err := scores[user.ID].Result()
+		if err == nil && heartbeatAlive(score) {
+			presence.Status = PresenceOnline
+			presence.LastSeenAt = nil
+			if status, _ := statuses[user.ID].Result(); status == PresenceAway {
+				presence.Status = PresenceAway
+			}
+		} else if err == nil {
+			// The instance holding the connection died, its last heartbeat
+			// is the best guess.
+			lastSeen := time.Unix(int64(score), 0)
+			if presence.LastSeenAt == nil || presence.LastSeenAt.Before(lastSeen) {
+				presence.LastSeenAt = &lastSeen
+			}
+		}
+		result = append(result, presence)
+	}
+	return result, nil
+}
+
+// presence returns the status of the users in ?user_ids, a comma separated
+// list.
+func (h *EventsRouter) presence(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	userIDs := []uint{}
+	for _, value := range strings.Split(c.Query("user_ids"), ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil && id > 0 {
+			userIDs = append(userIDs, uint(id))
+		}
+	}
+	if len(userIDs) == 0 || len(userIDs) > maxPresenceUsers {
+		c.JSON(400, gin.H{"error": "Bad Request"})
+		return
+	}
+
+	presences, err := Presences(session.ID, userIDs)
+	if err != nil {
+		log.Error("Error loading presence", err)
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(200, presences)
+}
+
+// setPresence lets a connected user mark itself away and back online.
+func (h *EventsRouter) setPresence(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := &PresencePayload{}
+	if err := c.ShouldBind(payload); err != nil {
+		c.JSON(400, gin.H{"error": "Bad Request"})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, PresenceErrors{
+			Status: errorsMap["Status"],
+		})
+		return
+	}
+
+	ctx := context.Background()
+	member := strconv.Itoa(int(session.ID))
+	score, err := db.DefaultCache.ZScore(ctx, presenceKey, member).Result()
+	if err != nil || !heartbeatAlive(score) {
+		c.JSON(409, gin.H{"error": "Not connected"})
+		return
+	}
+
+	previous, _ := db.DefaultCache.HGet(ctx, presenceStatusKey, member).Result()
+	if previous == "" {
+		previous = PresenceOnline
+	}
+	if payload.Status == PresenceAway {
+		err = db.DefaultCache.HSet(ctx, presenceStatusKey, member, PresenceAway).Err()
+	} else {
+		err = db.DefaultCache.HDel(ctx, presenceStatusKey, member).Err()
+	}
+	if err != nil {
+		log.Error("Error saving presence", err)
+		c.JSON(500, gin.H{"error": "Internal server error"})
+		return
+	}
+	if previous != payload.Status {
+		h.notifyPresence(session.ID, payload.Status)
+	}
+
+	c.JSON(200, gin.H{"status": payload.Status})
+}
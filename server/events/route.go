@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/juliotorresmoreno/specialist-talk-api/db"
@@ -72,10 +73,29 @@ func (h *EventsRouter) subscribe() {
 	}
 }
 
+// Send delivers an event to every connection of a user, data is encoded as
+// json.
+func (h *EventsRouter) Send(userID uint, eventType string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Error("Error encoding event: ", err)
+		return
+	}
+	h.Handler <- &Request{
+		ID: userID,
+		Event: &Event{
+			Type: eventType,
+			Data: string(payload),
+		},
+	}
+}
+
 func SetupAPIRoutes(g *gin.RouterGroup) chan *Request {
 	h := DefaultEventsRouter
 
 	g.GET("", h.Subscribe)
+	g.GET("/presence", h.presence)
+	g.PUT("/presence", h.setPresence)
 	g.POST("/:id", h.Publish)
 
 	return h.Handler
@@ -118,7 +138,7 @@ func (h *EventsRouter) Run() {
 func (h *EventsRouter) Subscribe(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
+		utils.Response(c, err)
 		return
 	}
 
@@ -134,9 +154,11 @@ func (h *EventsRouter) Subscribe(c *gin.Context) {
 		h.Unregister <- client
 		close(ch)
 		close(done)
+		h.disconnect(session.ID)
 		log.Info("Unregistering client")
 	}()
 
+	h.connect(session.ID)
 	h.Register <- client
 	c.Header("Content-Type", "text/event-stream")
 	c.Status(200)
@@ -144,12 +166,17 @@ func (h *EventsRouter) Subscribe(c *gin.Context) {
 	c.SSEvent("connected", "Connected")
 	c.Writer.Flush()
 
+	heartbeat := time.NewTicker(presenceHeartbeat)
+	defer heartbeat.Stop()
+
 	for {
 		select {
 		case <-done:
 			return
 		case <-c.Writer.CloseNotify():
 			return
+		case <-heartbeat.C:
+			h.heartbeat(session.ID)
 		case event := <-ch:
 			c.SSEvent(event.Type, event.Data)
 			c.Writer.Flush()
@@ -160,7 +187,7 @@ func (h *EventsRouter) Subscribe(c *gin.Context) {
 func (h *EventsRouter) Publish(c *gin.Context) {
 	_, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
+		utils.Response(c, err)
 		return
 	}
 
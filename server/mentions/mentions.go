@@ -0,0 +1,141 @@
+package mentions
+
+import (
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/notifications"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
+)
+
+type Mention struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// Save replaces the mentions of a source with the @usernames in content
+// and returns the users that were not mentioned before. Scopes restrict
+// which users can be mentioned.
+func Save(tx *gorm.DB, sourceType string, sourceID uint, content string, scopes ...func(*gorm.DB) *gorm.DB) ([]uint, error) {
+	previous := []uint{}
+	err := tx.Model(&models.Mention{}).
+		Where(&models.Mention{SourceType: sourceType, SourceId: sourceID}).
+		Distinct().
+		Pluck("user_id", &previous).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Where(&models.Mention{SourceType: sourceType, SourceId: sourceID}).
+		Delete(&models.Mention{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := utils.ParseMentions(content)
+	if len(ranges) == 0 {
+		return []uint{}, nil
+	}
+
+	usernames := []string{}
+	for _, mention := range ranges {
+		usernames = append(usernames, mention.Username)
+	}
+	users := []models.User{}
+	err = tx.Model(&models.User{}).
+		Select("id", "username").
+		Scopes(scopes...).
+		Where("username IN ?", usernames).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	byUsername := map[string]uint{}
+	for _, user := range users {
+		byUsername[user.Username] = user.ID
+	}
+
+	records := []models.Mention{}
+	mentioned := []uint{}
+	seen := map[uint]bool{}
+	for _, id := range previous {
+		seen[id] = true
+	}
+	for _, mention := range ranges {
+		userID, ok := byUsername[mention.Username]
+		if !ok {
+			continue
+		}
+		records = append(records, models.Mention{
+			SourceType: sourceType,
+			SourceId:   sourceID,
+			UserId:     userID,
+			Start:      mention.Start,
+			End:        mention.End,
+		})
+		if !seen[userID] {
+			seen[userID] = true
+			mentioned = append(mentioned, userID)
+		}
+	}
+	if len(records) == 0 {
+		return []uint{}, nil
+	}
+
+	return mentioned, tx.Create(&records).Error
+}
+
+// Notify sends a notification to every mentioned user, which reaches them
+// live too unless they turned mention notifications off.
+func Notify(actorID uint, sourceType string, sourceID uint, userIDs []uint) {
+	for _, userID := range userIDs {
+		if userID == actorID {
+			continue
+		}
+		notifications.Notify(userID, actorID, models.NotificationMention, sourceType, sourceID)
+	}
+}
+
+// Mentioned returns the users mentioned in a source, for the notifications
+// that wait until the source is visible.
+func Mentioned(sourceType string, sourceID uint) ([]uint, error) {
+	mentioned := []uint{}
+	err := db.DefaultClient.
+		Model(&models.Mention{}).
+		Where("source_type = ? AND source_id = ?", sourceType, sourceID).
+		Distinct().
+		Pluck("user_id", &mentioned).Error
+	return mentioned, err
+}
+
+// Find loads the mentions of the given sources grouped by source id.
+func Find(sourceType string, sourceIDs []uint) map[uint][]Mention {
+	result := map[uint][]Mention{}
+	if len(sourceIDs) == 0 {
+		return result
+	}
+
+	records := []models.Mention{}
+	err := db.DefaultClient.
+		Preload("User", func(tx *gorm.DB) *gorm.DB {
+			return tx.Select("id", "username")
+		}).
+		Where("source_type = ? AND source_id IN ?", sourceType, sourceIDs).
+		Order("start").
+		Find(&records).Error
+	if err != nil {
+		return result
+	}
+
+	for _, record := range records {
+		result[record.SourceId] = append(result[record.SourceId], Mention{
+			UserID:   record.UserId,
+			Username: record.User.Username,
+			Start:    record.Start,
+			End:      record.End,
+		})
+	}
+	return result
+}
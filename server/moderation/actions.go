@@ -0,0 +1,336 @@
+package moderation
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/chats"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/notifications"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/posts"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
+)
+
+// defaultSuspension applies when a suspension does not say how many days
+// it lasts.
+const defaultSuspension = 7
+
+type ModerationAction struct {
+	ID           uint      `json:"id"`
+	ModeratorID  uint      `json:"moderator_id"`
+	Moderator    User      `json:"moderator"`
+	TargetType   string    `json:"target_type"`
+	TargetID     uint      `json:"target_id"`
+	TargetUserID uint      `json:"target_user_id"`
+	Action       string    `json:"action"`
+	Reason       string    `json:"reason"`
+	ReportID     *uint     `json:"report_id,omitempty"`
+	CreationAt   time.Time `json:"creation_at"`
+}
+
+type ActionPayload struct {
+	TargetType string `json:"target_type" validate:"omitempty,oneof=post comment message user"`
+	TargetID   uint   `json:"target_id"`
+	Action     string `json:"action" validate:"required,oneof=hide restore delete warn suspend dismiss"`
+	Reason     string `json:"reason" validate:"required,max=1000"`
+	Days       int    `json:"days" validate:"omitempty,min=1,max=365"`
+}
+
+type ActionErrors struct {
+	TargetType string `json:"target_type,omitempty"`
+	Action     string `json:"action,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Days       string `json:"days,omitempty"`
+}
+
+func bindAction(c *gin.Context, payload *ActionPayload) bool {
+	err := c.ShouldBind(payload)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "Invalid payload",
+		})
+		return false
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, ActionErrors{
+			TargetType: errorsMap["TargetType"],
+			Action:     errorsMap["Action"],
+			Reason:     errorsMap["Reason"],
+			Days:       errorsMap["Days"],
+		})
+		return false
+	}
+	return true
+}
+
+// targetOwner returns the user responsible for the target, the user itself
+// when a profile is the target. Messages can only be reported by members
+// of their chat.
+func targetOwner(userID uint, targetType string, targetID uint) (uint, bool) {
+	ids := []uint{}
+	switch targetType {
+	case models.ModerationTargetPost:
+		db.DefaultClient.
+			Model(&models.Post{}).
+			Where("id = ?", targetID).
+			Pluck("author_id", &ids)
+	case models.ModerationTargetComment:
+		db.DefaultClient.
+			Model(&models.Comment{}).
+			Where("id = ?", targetID).
+			Pluck("author_id", &ids)
+	case models.ModerationTargetMessage:
+		tx := db.DefaultClient.
+			Model(&models.Message{}).
+			Where("id = ?", targetID)
+		if userID != 0 {
+			tx = tx.Where(
+				"chat_id IN (SELECT chat_id FROM chat_users WHERE user_id = ? AND deleted_at IS NULL) OR "+
+					"chat_id IN (SELECT id FROM chats WHERE owner_id = ?)",
+				userID, userID,
+			)
+		}
+		tx.Pluck("user_id", &ids)
+	case models.ModerationTargetUser:
+		db.DefaultClient.
+			Model(&models.User{}).
+			Where("id = ?", targetID).
+			Pluck("id", &ids)
+	}
+	if len(ids) == 0 {
+		return 0, false
+	}
+	return ids[0], true
+}
+
+// contentModel is the model holding each kind of content that can be
+// hidden or deleted.
+func contentModel(targetType string) interface{} {
+	switch targetType {
+	case models.ModerationTargetPost:
+		return &models.Post{}
+	case models.ModerationTargetComment:
+		return &models.Comment{}
+	case models.ModerationTargetMessage:
+		return &models.Message{}
+	}
+	return nil
+}
+
+// apply takes a moderation action on a target, records it in the audit
+// trail and closes every open report of the target, whose reporters are
+// notified of the outcome.
+func apply(moderatorID uint, targetType string, targetID uint, payload *ActionPayload, reportID *uint) (*ModerationAction, error) {
+	ownerID, ok := targetOwner(0, targetType, targetID)
+	if !ok {
+		return nil, utils.StatusNotFound
+	}
+	model := contentModel(targetType)
+	content := payload.Action == models.ModerationActionHide ||
+		payload.Action == models.ModerationActionRestore ||
+		payload.Action == models.ModerationActionDelete
+	if model == nil && content {
+		return nil, utils.StatusBadRequest
+	}
+
+	action := &models.ModerationAction{
+		ModeratorId:  moderatorID,
+		TargetType:   targetType,
+		TargetId:     targetID,
+		TargetUserId: ownerID,
+		Action:       payload.Action,
+		Reason:       payload.Reason,
+		ReportId:     reportID,
+	}
+	status := models.ReportStatusResolved
+	if payload.Action == models.ModerationActionDismiss {
+		status = models.ReportStatusDismissed
+	}
+
+	reporters := []uint{}
+	held := int64(0)
+	err := db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		var err error
+		switch payload.Action {
+		case models.ModerationActionHide:
+			err = tx.Model(model).Where("id = ?", targetID).Update("hidden", true).Error
+		case models.ModerationActionRestore:
+			// Content held by the filter was never delivered, the report
+			// opened when holding it tells it apart.
+			err = tx.Model(&models.Report{}).
+				Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, models.ReportStatusOpen).
+				Where("reason = ? AND reporter_id IS NULL", models.ReportReasonAutomated).
+				Count(&held).Error
+			if err == nil {
+				err = tx.Model(model).Where("id = ?", targetID).Update("hidden", false).Error
+			}
+		case models.ModerationActionDelete:
+			if targetType == models.ModerationTargetPost {
+				err = posts.DeletePost(tx, targetID)
+			} else {
+				err = tx.Where("id = ?", targetID).Delete(model).Error
+			}
+		case models.ModerationActionSuspend:
+			days := payload.Days
+			if days == 0 {
+				days = defaultSuspension
+			}
+			err = tx.Model(&models.User{}).
+				Where("id = ?", ownerID).
+				Update("suspended_until", time.Now().AddDate(0, 0, days)).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(action).Error; err != nil {
+			return err
+		}
+
+		open := &models.Report{TargetType: targetType, TargetId: targetID, Status: models.ReportStatusOpen}
+		err = tx.Model(&models.Report{}).
+			Where(open).
+			Where("reporter_id IS NOT NULL").
+			Distinct().
+			Pluck("reporter_id", &reporters).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&models.Report{}).
+			Where(open).
+			Updates(&models.Report{Status: status, ResolvedById: &moderatorID, ResolvedAt: &now}).Error
+	})
+	if err != nil {
+		log.Error("Error applying moderation action", err)
+		return nil, utils.StatusInternalServerError
+	}
+
+	if payload.Action == models.ModerationActionRestore && held > 0 {
+		release(targetType, targetID)
+	}
+	if payload.Action == models.ModerationActionWarn {
+		notifications.Notify(ownerID, moderatorID, models.NotificationWarning, "moderation_action", action.ID)
+	}
+	for _, reporterID := range reporters {
+		notifications.Notify(reporterID, moderatorID, models.NotificationReport, targetType, targetID)
+	}
+
+	return &ModerationAction{
+		ID:           action.ID,
+		ModeratorID:  action.ModeratorId,
+		TargetType:   action.TargetType,
+		TargetID:     action.TargetId,
+		TargetUserID: action.TargetUserId,
+		Action:       action.Action,
+		Reason:       action.Reason,
+		ReportID:     action.ReportId,
+		CreationAt:   action.CreationAt,
+	}, nil
+}
+
+// release delivers restored content the filter held back, running what
+// creating it skipped. Posts not published yet wait for the scheduler.
+func release(targetType string, targetID uint) {
+	var err error
+	switch targetType {
+	case models.ModerationTargetPost:
+		post := &models.Post{}
+		err = db.DefaultClient.
+			Where("id = ? AND status = ?", targetID, models.PostStatusPublished).
+			First(post).Error
+		if err == nil {
+			posts.AfterPublish(post)
+		}
+	case models.ModerationTargetComment:
+		comment := &models.Comment{}
+		err = db.DefaultClient.Where("id = ?", targetID).First(comment).Error
+		if err == nil {
+			posts.AfterComment(comment)
+		}
+	case models.ModerationTargetMessage:
+		message := &models.Message{}
+		err = db.DefaultClient.Where("id = ?", targetID).First(message).Error
+		if err == nil {
+			chats.AfterSend(message)
+		}
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error("Error releasing restored content", err)
+	}
+}
+
+// act takes an action without a report, open reports of the target are
+// closed all the same.
+func (h *ModerationRouter) act(c *gin.Context) {
+	session, ok := moderator(c)
+	if !ok {
+		return
+	}
+
+	payload := &ActionPayload{}
+	if !bindAction(c, payload) {
+		return
+	}
+	if payload.TargetType == "" || payload.TargetID == 0 {
+		c.JSON(http.StatusBadRequest, ActionErrors{
+			TargetType: "This field is required!",
+		})
+		return
+	}
+
+	action, err := apply(session.ID, payload.TargetType, payload.TargetID, payload, nil)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	c.JSON(201, action)
+}
+
+// actions is the audit trail of moderation, filtered by target, moderator
+// or affected user.
+func (h *ModerationRouter) actions(c *gin.Context) {
+	if _, ok := moderator(c); !ok {
+		return
+	}
+
+	tx := db.DefaultClient.
+		Model(&models.ModerationAction{}).
+		Preload("Moderator")
+	if targetType := c.Query("target_type"); targetType != "" {
+		targetID, _ := strconv.Atoi(c.Query("target_id"))
+		tx = tx.Where("target_type = ? AND target_id = ?", targetType, targetID)
+	}
+	if moderatorID, _ := strconv.Atoi(c.Query("moderator_id")); moderatorID > 0 {
+		tx = tx.Where("moderator_id = ?", moderatorID)
+	}
+	if userID, _ := strconv.Atoi(c.Query("user_id")); userID > 0 {
+		tx = tx.Where("target_user_id = ?", userID)
+	}
+
+	cursor := utils.ParseCursor(c)
+	actions := []*ModerationAction{}
+	err := cursor.Apply(tx, "moderation_actions.id").Find(&actions).Error
+	if err != nil {
+		log.Error("Error getting moderation actions", err)
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if cursor.Reversed() {
+		slices.Reverse(actions)
+	}
+
+	c.JSON(200, actions)
+}
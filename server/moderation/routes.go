@@ -0,0 +1,244 @@
+package moderation
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/logger"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/contentfilter"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+)
+
+var log = logger.SetupLogger()
+
+type ModerationRouter struct{}
+
+func SetupAPIRoutes(g *gin.RouterGroup) {
+	h := &ModerationRouter{}
+
+	g.POST("/reports", h.report)
+	g.GET("/reports", h.reports)
+	g.POST("/reports/:id/resolve", h.resolve)
+	g.GET("/actions", h.actions)
+	g.POST("/actions", h.act)
+	g.GET("/filters", h.filters)
+}
+
+type User struct {
+	ID        uint   `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Username  string `json:"username"`
+	PhotoURL  string `json:"photo_url"`
+}
+
+type Report struct {
+	ID           uint       `json:"id"`
+	ReporterID   *uint      `json:"reporter_id"`
+	Reporter     *User      `json:"reporter"`
+	TargetType   string     `json:"target_type"`
+	TargetID     uint       `json:"target_id"`
+	Reason       string     `json:"reason"`
+	Details      string     `json:"details"`
+	Status       string     `json:"status"`
+	Reports      int64      `json:"reports" gorm:"->"`
+	ResolvedByID *uint      `json:"resolved_by_id,omitempty"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	CreationAt   time.Time  `json:"creation_at"`
+}
+
+type ReportPayload struct {
+	TargetType string `json:"target_type" validate:"required,oneof=post comment message user"`
+	TargetID   uint   `json:"target_id" validate:"required"`
+	Reason     string `json:"reason" validate:"required,oneof=spam harassment hate violence nudity misinformation impersonation other"`
+	Details    string `json:"details" validate:"max=1000"`
+}
+
+type ReportErrors struct {
+	TargetType string `json:"target_type,omitempty"`
+	TargetID   string `json:"target_id,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Details    string `json:"details,omitempty"`
+}
+
+// moderator validates the session and checks the user has a moderator
+// role, answering the request otherwise.
+func moderator(c *gin.Context) (*utils.User, bool) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return nil, false
+	}
+	if !session.IsModerator() {
+		c.JSON(403, gin.H{
+			"message": "Forbidden",
+		})
+		return nil, false
+	}
+	return session, true
+}
+
+func (h *ModerationRouter) report(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := &ReportPayload{}
+	err = c.ShouldBind(payload)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "Invalid payload",
+		})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, ReportErrors{
+			TargetType: errorsMap["TargetType"],
+			TargetID:   errorsMap["TargetID"],
+			Reason:     errorsMap["Reason"],
+			Details:    errorsMap["Details"],
+		})
+		return
+	}
+
+	if _, ok := targetOwner(session.ID, payload.TargetType, payload.TargetID); !ok {
+		c.JSON(404, gin.H{
+			"message": "Not found",
+		})
+		return
+	}
+
+	count := int64(0)
+	db.DefaultClient.
+		Model(&models.Report{}).
+		Where(&models.Report{
+			ReporterId: &session.ID,
+			TargetType: payload.TargetType,
+			TargetId:   payload.TargetID,
+			Status:     models.ReportStatusOpen,
+		}).
+		Count(&count)
+	if count > 0 {
+		c.JSON(200, gin.H{"message": "Already reported"})
+		return
+	}
+
+	err = db.DefaultClient.Create(&models.Report{
+		ReporterId: &session.ID,
+		TargetType: payload.TargetType,
+		TargetId:   payload.TargetID,
+		Reason:     payload.Reason,
+		Details:    payload.Details,
+	}).Error
+	if err != nil {
+		log.Error("Error creating report", err)
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(201, gin.H{"message": "Reported"})
+}
+
+// reports is the moderation queue, every report carries how many open
+// reports its target has so the most reported content can be triaged first.
+func (h *ModerationRouter) reports(c *gin.Context) {
+	if _, ok := moderator(c); !ok {
+		return
+	}
+
+	tx := db.DefaultClient.
+		Model(&models.Report{}).
+		Select("reports.*, (SELECT count(*) FROM reports others WHERE others.target_type = reports.target_type " +
+			"AND others.target_id = reports.target_id AND others.status = 'open') AS reports").
+		Preload("Reporter").
+		Where(&models.Report{Status: c.DefaultQuery("status", models.ReportStatusOpen)})
+	if targetType := c.Query("target_type"); targetType != "" {
+		tx = tx.Where(&models.Report{TargetType: targetType})
+	}
+
+	cursor := utils.ParseCursor(c)
+	reports := []*Report{}
+	err := cursor.Apply(tx, "reports.id").Find(&reports).Error
+	if err != nil {
+		log.Error("Error getting reports", err)
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if cursor.Reversed() {
+		slices.Reverse(reports)
+	}
+
+	c.JSON(200, reports)
+}
+
+func (h *ModerationRouter) resolve(c *gin.Context) {
+	session, ok := moderator(c)
+	if !ok {
+		return
+	}
+
+	payload := &ActionPayload{}
+	if !bindAction(c, payload) {
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	report := &models.Report{}
+	err := db.DefaultClient.
+		Where(&models.Report{ID: uint(id)}).
+		First(report).Error
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "Report not found",
+		})
+		return
+	}
+	if report.Status != models.ReportStatusOpen {
+		c.JSON(409, gin.H{
+			"message": "Report already resolved",
+		})
+		return
+	}
+
+	action, err := apply(session.ID, report.TargetType, report.TargetId, payload, &report.ID)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	c.JSON(200, action)
+}
+
+// filters returns how many times each content filter rule took each
+// action.
+func (h *ModerationRouter) filters(c *gin.Context) {
+	if _, ok := moderator(c); !ok {
+		return
+	}
+
+	stats, err := contentfilter.Stats()
+	if err != nil {
+		log.Error("Error getting content filter stats", err)
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, stats)
+}
@@ -0,0 +1,74 @@
+package notifications
+
+import (
+	"time"
+
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/logger"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/events"
+)
+
+var log = logger.SetupLogger()
+
+type User struct {
+	ID        uint   `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Username  string `json:"username"`
+	PhotoURL  string `json:"photo_url"`
+}
+
+type Notification struct {
+	ID         uint       `json:"id"`
+	Type       string     `json:"type"`
+	ActorID    uint       `json:"actor_id"`
+	Actor      User       `json:"actor"`
+	TargetType string     `json:"target_type"`
+	TargetID   uint       `json:"target_id"`
+	ReadAt     *time.Time `json:"read_at"`
+	CreationAt time.Time  `json:"creation_at"`
+}
+
+// Notify stores a notification for userID and pushes it to the user's open
+// connections. Users are never notified of their own actions.
+func Notify(userID, actorID uint, notificationType, targetType string, targetID uint) {
+	if userID == actorID || !enabled(userID, notificationType) {
+		return
+	}
+
+	notification := &models.Notification{
+		UserId:     userID,
+		Type:       notificationType,
+		ActorId:    actorID,
+		TargetType: targetType,
+		TargetId:   targetID,
+	}
+	err := db.DefaultClient.Create(notification).Error
+	if err != nil {
+		log.Error("Error creating notification: ", err)
+		return
+	}
+
+	result := &Notification{}
+	err = db.DefaultClient.
+		Model(&models.Notification{}).
+		Preload("Actor").
+		Where(&models.Notification{ID: notification.ID}).
+		First(result).Error
+	if err != nil {
+		log.Error("Error loading notification: ", err)
+		return
+	}
+
+	events.DefaultEventsRouter.Send(userID, "notification", result)
+}
+
+func enabled(userID uint, notificationType string) bool {
+	count := int64(0)
+	db.DefaultClient.
+		Model(&models.NotificationPreference{}).
+		Where("user_id = ? AND type = ? AND enabled = false", userID, notificationType).
+		Count(&count)
+	return count == 0
+}
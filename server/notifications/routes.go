@@ -0,0 +1,237 @@
+package notifications
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationsRouter struct{}
+
+func SetupAPIRoutes(g *gin.RouterGroup) {
+	h := &NotificationsRouter{}
+
+	g.GET("", h.find)
+	g.GET("/unread", h.unread)
+	g.POST("/read", h.readAll)
+	g.POST("/:id/read", h.read)
+	g.GET("/preferences", h.getPreferences)
+	g.PATCH("/preferences", h.updatePreferences)
+}
+
+type Page struct {
+	Items  []*Notification `json:"items"`
+	Unread int64           `json:"unread"`
+}
+
+func unreadCount(userID uint) (int64, error) {
+	count := int64(0)
+	err := db.DefaultClient.
+		Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (h *NotificationsRouter) find(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	tx := db.DefaultClient.
+		Model(&models.Notification{}).
+		Preload("Actor").
+		Where(&models.Notification{UserId: session.ID})
+	if c.Query("unread") == "true" {
+		tx = tx.Where("read_at IS NULL")
+	}
+
+	cursor := utils.ParseCursor(c)
+	page := &Page{Items: []*Notification{}}
+	err = cursor.Apply(tx, "notifications.id").Find(&page.Items).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if cursor.Reversed() {
+		slices.Reverse(page.Items)
+	}
+
+	page.Unread, err = unreadCount(session.ID)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, page)
+}
+
+func (h *NotificationsRouter) unread(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	count, err := unreadCount(session.ID)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{"unread": count})
+}
+
+func (h *NotificationsRouter) read(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	err = db.DefaultClient.
+		Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", id, session.ID).
+		Update("read_at", time.Now()).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Notification read"})
+}
+
+func (h *NotificationsRouter) readAll(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	err = db.DefaultClient.
+		Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", session.ID).
+		Update("read_at", time.Now()).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Notifications read"})
+}
+
+// preferences returns whether each notification type is enabled for the
+// user, types without a stored preference are enabled.
+func preferences(userID uint) (map[string]bool, error) {
+	stored := []models.NotificationPreference{}
+	err := db.DefaultClient.
+		Where(&models.NotificationPreference{UserId: userID}).
+		Find(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]bool{}
+	for _, notificationType := range models.NotificationTypes {
+		result[notificationType] = true
+	}
+	for _, preference := range stored {
+		result[preference.Type] = preference.Enabled
+	}
+	return result, nil
+}
+
+func (h *NotificationsRouter) getPreferences(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	result, err := preferences(session.ID)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+func (h *NotificationsRouter) updatePreferences(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := map[string]bool{}
+	err = c.ShouldBind(&payload)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "Invalid payload",
+		})
+		return
+	}
+
+	updates := []models.NotificationPreference{}
+	for notificationType, enabled := range payload {
+		if !slices.Contains(models.NotificationTypes, notificationType) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				notificationType: "Invalid field!",
+			})
+			return
+		}
+		updates = append(updates, models.NotificationPreference{
+			UserId:  session.ID,
+			Type:    notificationType,
+			Enabled: enabled,
+		})
+	}
+
+	if len(updates) > 0 {
+		err = db.DefaultClient.
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+				DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+			}).
+			Create(&updates).Error
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "Internal server error",
+			})
+			return
+		}
+	}
+
+	result, err := preferences(session.ID)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, result)
+}
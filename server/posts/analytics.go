@@ -0,0 +1,272 @@
+package posts
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	analyticsLockKey  = "posts-analytics-lock"
+	analyticsLockTTL  = 50 * time.Second
+	analyticsInterval = time.Minute
+
+	// postViewsKey holds the impressions of each post not flushed yet.
+	postViewsKey = "post-views"
+
+	// authorViewersTTL keeps the daily viewers of an author until the day
+	// after, when its last flush happens.
+	authorViewersTTL = 48 * time.Hour
+
+	// postViewersTTL drops the viewers of posts nobody viewed in a while,
+	// the unique viewers saved never go back when they are counted anew.
+	postViewersTTL = 30 * 24 * time.Hour
+
+	// flushBatchField names the batch of the counters being flushed, it is
+	// kept in the hash along with the ids.
+	flushBatchField = "batch"
+	flushesKept     = 7 * 24 * time.Hour
+)
+
+func postViewersKey(postID uint) string {
+	return "post-viewers-" + strconv.Itoa(int(postID))
+}
+
+func authorViewsKey(day time.Time) string {
+	return "author-views-" + day.Format(time.DateOnly)
+}
+
+func authorViewersKey(day time.Time, authorID uint) string {
+	return "author-viewers-" + day.Format(time.DateOnly) + "-" + strconv.Itoa(int(authorID))
+}
+
+// trackViews counts an impression of every published post shown to the
+// viewer, reposts count for their original. Unique viewers are kept in
+// HyperLogLogs, anonymous viewers only add impressions.
+func trackViews(viewerID uint, posts []*Post) {
+	ctx := context.Background()
+	now := time.Now()
+	pipe := db.DefaultCache.Pipeline()
+	for _, post := range posts {
+		if post.Kind == models.PostKindRepost {
+			post = post.RepostOf
+		}
+		if post == nil || post.DeletedAt != nil || post.Status != models.PostStatusPublished {
+			continue
+		}
+		postID, authorID := uint(post.ID), uint(post.AuthorID)
+		if authorID == viewerID {
+			continue
+		}
+
+		pipe.HIncrBy(ctx, postViewsKey, strconv.Itoa(int(postID)), 1)
+		pipe.HIncrBy(ctx, authorViewsKey(now), strconv.Itoa(int(authorID)), 1)
+		pipe.Expire(ctx, authorViewsKey(now), authorViewersTTL)
+		if viewerID == 0 {
+			continue
+		}
+		pipe.PFAdd(ctx, postViewersKey(postID), viewerID)
+		pipe.Expire(ctx, postViewersKey(postID), postViewersTTL)
+		pipe.PFAdd(ctx, authorViewersKey(now, authorID), viewerID)
+		pipe.Expire(ctx, authorViewersKey(now, authorID), authorViewersTTL)
+	}
+	if pipe.Len() == 0 {
+		return
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Error("Error tracking post views", err)
+	}
+}
+
+// StartAnalytics flushes the view counters kept in Redis to Postgres. Every
+// API instance runs it, the Redis lock lets a single one work on each tick.
+func StartAnalytics() {
+	go func() {
+		ticker := time.NewTicker(analyticsInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			flushViews()
+		}
+	}()
+}
+
+// flushViews saves the views of each post and the daily views of each
+// author, yesterday is flushed too to catch the views of its last minute.
+func flushViews() {
+	withLock(analyticsLockKey, analyticsLockTTL, func() {
+		flushCounters(postViewsKey, savePostStats)
+
+		now := time.Now()
+		for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+			flushCounters(authorViewsKey(day), func(tx *gorm.DB, views map[uint]int64) error {
+				return saveAuthorStats(tx, day, views)
+			})
+		}
+
+		err := db.DefaultClient.
+			Where("creation_at < ?", time.Now().Add(-flushesKept)).
+			Delete(&models.StatsFlush{}).Error
+		if err != nil {
+			log.Error("Error clearing old flushes", err)
+		}
+	})
+}
+
+// flushCounters moves the counters of key aside while they are saved, a
+// failed save is retried on the next flush while new views keep counting.
+// The batch is marked as saved along with the counters, so it is cleared
+// without saving it again if clearing failed before.
+func flushCounters(key string, save func(*gorm.DB, map[uint]int64) error) {
+	ctx := context.Background()
+	flushing := key + "-flushing"
+
+	pending, err := db.DefaultCache.Exists(ctx, flushing).Result()
+	if err != nil {
+		log.Error("Error checking pending views", err)
+		return
+	}
+	if pending == 0 {
+		exists, err := db.DefaultCache.Exists(ctx, key).Result()
+		if err != nil || exists == 0 {
+			return
+		}
+		if err := db.DefaultCache.Rename(ctx, key, flushing).Err(); err != nil {
+			log.Error("Error moving views to flush", err)
+			return
+		}
+	}
+
+	batch, err := utils.GenerateRandomString(32)
+	if err != nil {
+		log.Error("Error naming views batch", err)
+		return
+	}
+	err = db.DefaultCache.HSetNX(ctx, flushing, flushBatchField, batch).Err()
+	if err != nil {
+		log.Error("Error naming views batch", err)
+		return
+	}
+	values, err := db.DefaultCache.HGetAll(ctx, flushing).Result()
+	if err != nil {
+		log.Error("Error loading views to flush", err)
+		return
+	}
+	batch = values[flushBatchField]
+	delete(values, flushBatchField)
+
+	views := map[uint]int64{}
+	for field, value := range values {
+		id, _ := strconv.Atoi(field)
+		count, _ := strconv.ParseInt(value, 10, 64)
+		if id > 0 && count > 0 {
+			views[uint(id)] = count
+		}
+	}
+	if len(views) > 0 {
+		err := db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+			result := tx.
+				Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.StatsFlush{Batch: key + "-" + batch})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return save(tx, views)
+		})
+		if err != nil {
+			log.Error("Error flushing views", err)
+			return
+		}
+	}
+
+	if err := db.DefaultCache.Del(ctx, flushing).Err(); err != nil {
+		log.Error("Error clearing flushed views", err)
+	}
+}
+
+// countViewers reads the unique viewers of each HyperLogLog in a single
+// round trip.
+func countViewers(keys map[uint]string) (map[uint]int64, error) {
+	ctx := context.Background()
+	pipe := db.DefaultCache.Pipeline()
+	counts := map[uint]*redis.IntCmd{}
+	for id, key := range keys {
+		counts[id] = pipe.PFCount(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	viewers := map[uint]int64{}
+	for id, count := range counts {
+		viewers[id] = count.Val()
+	}
+	return viewers, nil
+}
+
+func savePostStats(tx *gorm.DB, views map[uint]int64) error {
+	keys := map[uint]string{}
+	for postID := range views {
+		keys[postID] = postViewersKey(postID)
+	}
+	viewers, err := countViewers(keys)
+	if err != nil {
+		return err
+	}
+
+	stats := []models.PostStat{}
+	for postID, count := range views {
+		stats = append(stats, models.PostStat{
+			PostId:        postID,
+			Views:         count,
+			UniqueViewers: viewers[postID],
+		})
+	}
+	return tx.
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "post_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"views":          gorm.Expr("post_stats.views + excluded.views"),
+				"unique_viewers": gorm.Expr("GREATEST(post_stats.unique_viewers, excluded.unique_viewers)"),
+				"updated_at":     gorm.Expr("excluded.updated_at"),
+			}),
+		}).
+		Create(&stats).Error
+}
+
+func saveAuthorStats(tx *gorm.DB, day time.Time, views map[uint]int64) error {
+	keys := map[uint]string{}
+	for authorID := range views {
+		keys[authorID] = authorViewersKey(day, authorID)
+	}
+	viewers, err := countViewers(keys)
+	if err != nil {
+		return err
+	}
+
+	date, _ := time.Parse(time.DateOnly, day.Format(time.DateOnly))
+	stats := []models.AuthorStat{}
+	for authorID, count := range views {
+		stats = append(stats, models.AuthorStat{
+			UserId:        authorID,
+			Day:           date,
+			Views:         count,
+			UniqueViewers: viewers[authorID],
+		})
+	}
+	return tx.
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"views":          gorm.Expr("author_stats.views + excluded.views"),
+				"unique_viewers": gorm.Expr("excluded.unique_viewers"),
+			}),
+		}).
+		Create(&stats).Error
+}
@@ -0,0 +1,168 @@
+package posts
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/juliotorresmoreno/specialist-talk-api/miniostorage"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+)
+
+const (
+	maxAttachmentSize       = 20 << 20
+	attachmentPreviewLength = 300
+)
+
+const (
+	mimeTypePPT  = "application/vnd.ms-powerpoint"
+	mimeTypePPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	mimeTypeODP  = "application/vnd.oasis.opendocument.presentation"
+)
+
+// attachmentTypes maps the accepted mime types to the extension used for
+// the stored object.
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpeg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+	mimeTypePPT:       ".ppt",
+	mimeTypePPTX:      ".pptx",
+	mimeTypeODP:       ".odp",
+}
+
+// Presentations are containers http.DetectContentType does not look into,
+// they are told by the signature of the container.
+var (
+	oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+	zipSignature = []byte("PK\x03\x04")
+)
+
+// matchesType tells whether the content of a file is of the type the
+// client declared for it.
+func matchesType(mimeType string, data []byte) bool {
+	switch mimeType {
+	case mimeTypePPT:
+		return bytes.HasPrefix(data, oleSignature)
+	case mimeTypePPTX, mimeTypeODP:
+		return bytes.HasPrefix(data, zipSignature)
+	}
+	return http.DetectContentType(data) == mimeType
+}
+
+type PostAttachment struct {
+	ID       uint   `json:"id"`
+	PostID   uint   `json:"-"`
+	Position int    `json:"position"`
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	AltText  string `json:"alt_text"`
+	Preview  string `json:"preview,omitempty" gorm:"->"`
+}
+
+type AttachmentPayload struct {
+	File    string `json:"file" validate:"required"`
+	AltText string `json:"alt_text" validate:"max=1000"`
+}
+
+func preloadAttachments(tx *gorm.DB) *gorm.DB {
+	return tx.
+		Select("*, left(text, ?) AS preview", attachmentPreviewLength).
+		Where("deleted_at IS NULL").
+		Order("position")
+}
+
+// uploadAttachments stores every file in the public bucket and returns the
+// attachments ready to be saved, PDFs get their text extracted. When a file
+// fails the ones already stored are removed.
+func uploadAttachments(payload []AttachmentPayload) ([]models.PostAttachment, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+
+	client, err := miniostorage.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := []models.PostAttachment{}
+	for position, file := range payload {
+		attachment, err := uploadAttachment(client, position, file)
+		if err != nil {
+			removeAttachments(attachments)
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+
+	return attachments, nil
+}
+
+func uploadAttachment(client *minio.Client, position int, file AttachmentPayload) (*models.PostAttachment, error) {
+	mimeType := utils.ParseBase64FileType(file.File)
+	extension, ok := attachmentTypes[mimeType]
+	if !ok {
+		return nil, utils.StatusBadRequest
+	}
+	encoded, err := utils.ParseBase64File(file.File)
+	if err != nil {
+		return nil, utils.StatusBadRequest
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, utils.StatusBadRequest
+	}
+	if len(decoded) > maxAttachmentSize || !matchesType(mimeType, decoded) {
+		return nil, utils.StatusBadRequest
+	}
+
+	attachment := &models.PostAttachment{
+		Position: position,
+		MimeType: mimeType,
+		Size:     int64(len(decoded)),
+		AltText:  file.AltText,
+	}
+	if strings.HasPrefix(mimeType, "image/") {
+		attachment.Width, attachment.Height, _ = utils.ImageSize(bytes.NewReader(decoded))
+	}
+	if mimeType == "application/pdf" {
+		attachment.Text, err = utils.ReadPDF(file.File)
+		if err != nil {
+			log.Error("Error extracting pdf text", err)
+		}
+	}
+
+	attachment.ObjectName = utils.GenerateRandomFileName("post_", extension)
+	_, err = client.PutObject(
+		context.Background(), os.Getenv("MINIO_BUCKET"), attachment.ObjectName,
+		bytes.NewReader(decoded), int64(len(decoded)),
+		minio.PutObjectOptions{ContentType: mimeType},
+	)
+	if err != nil {
+		return nil, err
+	}
+	attachment.URL = os.Getenv("ASSETS_PATH") + "/" + attachment.ObjectName
+
+	return attachment, nil
+}
+
+// removeAttachments deletes the objects of attachments that never made it
+// to the database.
+func removeAttachments(attachments []models.PostAttachment) {
+	for _, attachment := range attachments {
+		err := miniostorage.RemoveObject(os.Getenv("MINIO_BUCKET"), attachment.ObjectName)
+		if err != nil {
+			log.Error("Error removing attachment object", err)
+		}
+	}
+}
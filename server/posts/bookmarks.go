@@ -0,0 +1,126 @@
+package posts
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BookmarkPayload struct {
+	CollectionID *uint `json:"collection_id"`
+}
+
+// bookmarkScope restricts posts to the ones bookmarked by the user, when a
+// collection is given only the bookmarks saved in it are listed.
+func bookmarkScope(userID uint, collectionID *uint) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		tx = tx.Joins("JOIN bookmarks ON bookmarks.post_id = posts.id AND bookmarks.user_id = ?", userID)
+		if collectionID != nil {
+			tx = tx.Where("bookmarks.collection_id = ?", *collectionID)
+		}
+		return tx
+	}
+}
+
+// FindBookmarks lists the posts bookmarked by the user, the last saved
+// first. Cursor ids are posts, resolved to when the user bookmarked them.
+func FindBookmarks(userID uint, collectionID *uint, cursor utils.Cursor) ([]*Post, error) {
+	const position = "(SELECT anchor.creation_at, anchor.id FROM bookmarks anchor WHERE anchor.post_id = ? AND anchor.user_id = ?)"
+	tx := feedQuery(userID).Scopes(publishedScope, visibleScope(userID), bookmarkScope(userID, collectionID))
+	if cursor.Before > 0 {
+		tx = tx.Where("(bookmarks.creation_at, bookmarks.id) < "+position, cursor.Before, userID)
+	}
+	if cursor.After > 0 {
+		tx = tx.Where("(bookmarks.creation_at, bookmarks.id) > "+position, cursor.After, userID).
+			Order("bookmarks.creation_at ASC, bookmarks.id ASC")
+	} else {
+		tx = tx.Order("bookmarks.creation_at DESC, bookmarks.id DESC")
+	}
+	return findPage(userID, tx.Limit(cursor.Limit), cursor)
+}
+
+func (h *PostsRouter) bookmark(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	// The body is optional, a bare request saves the post uncollected.
+	payload := &BookmarkPayload{}
+	err = c.ShouldBind(payload)
+	if err != nil && err != io.EOF {
+		c.JSON(400, gin.H{
+			"message": "Invalid payload",
+		})
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if _, ok := findVisible(session.ID, uint(id)); !ok {
+		c.JSON(404, gin.H{
+			"message": "Post not found",
+		})
+		return
+	}
+
+	if payload.CollectionID != nil {
+		count := int64(0)
+		db.DefaultClient.
+			Model(&models.BookmarkCollection{}).
+			Where(&models.BookmarkCollection{ID: *payload.CollectionID, UserId: session.ID}).
+			Count(&count)
+		if count == 0 {
+			c.JSON(400, gin.H{
+				"message": "Invalid collection",
+			})
+			return
+		}
+	}
+
+	err = db.DefaultClient.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"collection_id"}),
+		}).
+		Create(&models.Bookmark{
+			UserId:       session.ID,
+			PostId:       uint(id),
+			CollectionId: payload.CollectionID,
+		}).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(201, gin.H{"message": "Bookmarked"})
+}
+
+func (h *PostsRouter) unbookmark(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	err = db.DefaultClient.
+		Where(&models.Bookmark{UserId: session.ID, PostId: uint(id)}).
+		Delete(&models.Bookmark{}).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Bookmark removed"})
+}
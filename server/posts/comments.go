@@ -1,77 +1,213 @@
 package posts
 
 import (
+	"database/sql"
+	"slices"
 	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/juliotorresmoreno/specialist-talk-api/db"
 	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/contentfilter"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/mentions"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/notifications"
 	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
 )
 
+const maxCommentLength = 1000
+
 type Comment struct {
-	ID         int        `json:"id"`
-	Content    string     `json:"content"`
-	AuthorID   uint       `json:"author_id"`
-	Author     User       `json:"author"`
-	CreationAt time.Time  `json:"creation_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	ID         int                `json:"id"`
+	Content    string             `json:"content"`
+	AuthorID   uint               `json:"author_id"`
+	Author     User               `json:"author"`
+	ParentID   *uint              `json:"parent_id,omitempty"`
+	Replies    int64              `json:"replies" gorm:"->"`
+	Likes      int64              `json:"likes" gorm:"->"`
+	Liked      bool               `json:"liked" gorm:"->"`
+	Reaction   *string            `json:"reaction" gorm:"->"`
+	Reactions  ReactionCounts     `json:"reactions" gorm:"->"`
+	Mentions   []mentions.Mention `json:"mentions" gorm:"-"`
+	Edited     bool               `json:"edited"`
+	EditedAt   *time.Time         `json:"edited_at,omitempty"`
+	CreationAt time.Time          `json:"creation_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+	DeletedAt  *time.Time         `json:"deleted_at,omitempty"`
+}
+
+func commentsQuery(viewerID uint) *gorm.DB {
+	return db.DefaultClient.
+		Model(&models.Comment{}).
+		Select(
+			"comments.*, "+
+				reactionsSelect("comments", models.ReactionTargetComment)+", "+
+				"(SELECT count(*) FROM comments replies WHERE replies.parent_id = comments.id AND replies.deleted_at IS NULL AND replies.hidden = false) AS replies",
+			sql.Named("viewer", viewerID),
+		).
+		Where("comments.hidden = false").
+		Preload("Author")
+}
+
+func findComments(tx *gorm.DB, cursor utils.Cursor) ([]*Comment, error) {
+	comments := []*Comment{}
+	err := cursor.Apply(tx, "comments.id").Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+	if cursor.Reversed() {
+		slices.Reverse(comments)
+	}
+
+	ids := []uint{}
+	for _, comment := range comments {
+		ids = append(ids, uint(comment.ID))
+	}
+	commentMentions := mentions.Find(models.MentionSourceComment, ids)
+	for _, comment := range comments {
+		comment.Mentions = commentMentions[uint(comment.ID)]
+	}
+
+	return comments, nil
 }
 
 func (h *PostsRouter) createComment(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
 	id, _ := strconv.Atoi(c.Param("id"))
 	payload := &Comment{}
 	err = c.ShouldBind(payload)
-	if err != nil {
+	if err != nil || payload.Content == "" || utf8.RuneCountInString(payload.Content) > maxCommentLength {
 		c.JSON(400, gin.H{
 			"message": "Invalid payload",
 		})
 		return
 	}
 
+	post, ok := findVisible(session.ID, uint(id))
+	if !ok {
+		c.JSON(404, gin.H{
+			"message": "Post not found",
+		})
+		return
+	}
+
+	parent := &models.Comment{}
+	if payload.ParentID != nil {
+		err = db.DefaultClient.
+			Where("comments.id = ? AND comments.post_id = ?", *payload.ParentID, post.ID).
+			First(parent).Error
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": "Invalid parent comment",
+			})
+			return
+		}
+	}
+
+	filtered := contentfilter.Check(session.ID, contentfilter.SourceComment, payload.Content)
+	if filtered.Rejected() {
+		c.JSON(400, gin.H{
+			"message": "Content not allowed",
+		})
+		return
+	}
+
 	comment := &models.Comment{
-		Content:  payload.Content,
+		Content:  filtered.Content,
 		AuthorId: session.ID,
-		PostId:   uint(id),
+		PostId:   post.ID,
+		ParentId: payload.ParentID,
+		Hidden:   filtered.Held(),
 	}
-	err = db.DefaultClient.Create(comment).Error
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		_, err := mentions.Save(tx, models.MentionSourceComment, comment.ID, comment.Content)
+		return err
+	})
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "Internal server error",
 		})
 		return
 	}
+	if comment.Hidden {
+		contentfilter.Hold(models.ModerationTargetComment, comment.ID, filtered.Rules)
+		c.JSON(202, gin.H{"message": "Held for review"})
+		return
+	}
+	AfterComment(comment)
 
 	c.JSON(201, gin.H{"message": "Comment created"})
 }
 
-func (h *PostsRouter) getComments(c *gin.Context) {
-	_, err := utils.ValidateSession(c)
+// AfterComment notifies the author of the post, the author of the parent
+// comment and the users mentioned in a comment that just became visible.
+// Held comments go through it once a moderator restores them.
+func AfterComment(comment *models.Comment) {
+	post := &models.Post{}
+	err := db.DefaultClient.
+		Select("id", "author_id").
+		Where("posts.id = ?", comment.PostId).
+		First(post).Error
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		log.Error("Error loading commented post", err)
 		return
 	}
 
-	id, _ := strconv.Atoi(c.Param("id"))
+	mentioned, err := mentions.Mentioned(models.MentionSourceComment, comment.ID)
+	if err != nil {
+		log.Error("Error loading comment mentions", err)
+	} else {
+		mentions.Notify(comment.AuthorId, models.MentionSourceComment, comment.ID, audienceOf(post.ID, mentioned))
+	}
+	notifications.Notify(post.AuthorId, comment.AuthorId, models.NotificationComment, models.MentionSourceComment, comment.ID)
 
-	comments := &[]Comment{}
+	if comment.ParentId == nil {
+		return
+	}
+	parentAuthors := []uint{}
 	err = db.DefaultClient.
 		Model(&models.Comment{}).
-		Preload("Author").
-		Where(&models.Comment{PostId: uint(id)}).
-		Find(comments).Error
+		Where("comments.id = ?", *comment.ParentId).
+		Pluck("author_id", &parentAuthors).Error
+	if err != nil {
+		log.Error("Error loading parent comment", err)
+		return
+	}
+	if len(parentAuthors) > 0 && parentAuthors[0] != post.AuthorId {
+		notifications.Notify(parentAuthors[0], comment.AuthorId, models.NotificationReply, models.MentionSourceComment, comment.ID)
+	}
+}
+
+func (h *PostsRouter) getComments(c *gin.Context) {
+	viewerID, ok := viewerOf(c)
+	if !ok {
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if _, ok := findVisible(viewerID, uint(id)); !ok {
+		c.JSON(404, gin.H{
+			"message": "Post not found",
+		})
+		return
+	}
+
+	comments, err := findComments(
+		commentsQuery(viewerID).
+			Where(&models.Comment{PostId: uint(id)}).
+			Where("comments.parent_id IS NULL"),
+		utils.ParseCursor(c),
+	)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "Internal server error",
@@ -82,27 +218,139 @@ func (h *PostsRouter) getComments(c *gin.Context) {
 	c.JSON(200, comments)
 }
 
-func (h *PostsRouter) deleteComment(c *gin.Context) {
+func (h *PostsRouter) getReplies(c *gin.Context) {
+	viewerID, ok := viewerOf(c)
+	if !ok {
+		return
+	}
+
+	postID, _ := strconv.Atoi(c.Param("id"))
+	commentID, _ := strconv.Atoi(c.Param("commentId"))
+	parentID := uint(commentID)
+	if _, ok := findVisible(viewerID, uint(postID)); !ok {
+		c.JSON(404, gin.H{
+			"message": "Post not found",
+		})
+		return
+	}
+
+	comments, err := findComments(
+		commentsQuery(viewerID).
+			Where(&models.Comment{PostId: uint(postID), ParentId: &parentID}),
+		utils.ParseCursor(c),
+	)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, comments)
+}
+
+func (h *PostsRouter) updateComment(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
+		utils.Response(c, err)
+		return
+	}
+
+	postID, _ := strconv.Atoi(c.Param("id"))
+	commentID, _ := strconv.Atoi(c.Param("commentId"))
+	payload := &Comment{}
+	err = c.ShouldBind(payload)
+	if err != nil || payload.Content == "" || utf8.RuneCountInString(payload.Content) > maxCommentLength {
+		c.JSON(400, gin.H{
+			"message": "Invalid payload",
 		})
 		return
 	}
 
+	filtered := contentfilter.CheckEdit(session.ID, contentfilter.SourceComment, payload.Content)
+	if filtered.Rejected() {
+		c.JSON(400, gin.H{
+			"message": "Content not allowed",
+		})
+		return
+	}
+
+	now := time.Now()
+	found := false
+	mentioned := []uint{}
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Comment{}).
+			Where("comments.id = ? AND comments.post_id = ? AND comments.author_id = ?", commentID, postID, session.ID).
+			Updates(&models.Comment{Content: filtered.Content, Edited: true, EditedAt: &now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		found = true
+		mentioned, err = mentions.Save(tx, models.MentionSourceComment, uint(commentID), filtered.Content)
+		return err
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if !found {
+		c.JSON(404, gin.H{
+			"message": "Comment not found",
+		})
+		return
+	}
+	mentions.Notify(session.ID, models.MentionSourceComment, uint(commentID), audienceOf(uint(postID), mentioned))
+
+	c.JSON(200, gin.H{"message": "Comment updated"})
+}
+
+func (h *PostsRouter) deleteComment(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
 	postID, _ := strconv.Atoi(c.Param("id"))
 	commentID, _ := strconv.Atoi(c.Param("commentId"))
 
-	err = db.DefaultClient.
-		Where(&models.Comment{ID: uint(commentID), AuthorId: session.ID, PostId: uint(postID)}).
-		Delete(&models.Comment{}).Error
+	// Comments can be removed by their author or by the author of the post,
+	// their replies go along with them.
+	found := false
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Where("comments.id = ? AND comments.post_id = ?", commentID, postID).
+			Where(
+				"comments.author_id = ? OR EXISTS (SELECT 1 FROM posts WHERE posts.id = comments.post_id AND posts.author_id = ?)",
+				session.ID, session.ID,
+			).
+			Delete(&models.Comment{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		found = true
+		return tx.Exec(
+			"WITH RECURSIVE thread AS ("+
+				"SELECT id FROM comments WHERE parent_id = @comment "+
+				"UNION SELECT comments.id FROM comments JOIN thread ON comments.parent_id = thread.id"+
+				") UPDATE comments SET deleted_at = now() WHERE id IN (SELECT id FROM thread) AND deleted_at IS NULL",
+			sql.Named("comment", commentID),
+		).Error
+	})
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "Internal server error",
 		})
 		return
 	}
+	if !found {
+		c.JSON(404, gin.H{
+			"message": "Comment not found",
+		})
+		return
+	}
 
 	c.JSON(200, gin.H{"message": "Comment deleted"})
 }
@@ -0,0 +1,428 @@
+package posts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// homeFeedSize is how many post ids are kept materialized per user, older
+// pages are assembled at query time.
+const homeFeedSize = 500
+
+// homeFeedTTL bounds how long a materialized home feed lives. Pushes do not
+// extend it, so changes homeScope depends on but nothing invalidates are
+// picked up when the feed is rebuilt.
+const homeFeedTTL = 30 * time.Minute
+
+const (
+	discoverFeedKey    = "feed-discover"
+	discoverFeedSize   = 500
+	discoverFeedTTL    = time.Minute
+	discoverFeedWindow = "7 days"
+)
+
+// Home feeds score posts by publish time in milliseconds, members are
+// zero padded ids so posts published in the same millisecond are ordered
+// by id too.
+//
+// feedSentinel keeps a warm but empty home feed from being rebuilt on
+// every read. It scores lowest and is never returned, feeds without it were
+// materialized in an older layout and are rebuilt.
+var feedSentinel = feedMember(0)
+
+// errOutsideFeed tells that a cursor points past the materialized window.
+var errOutsideFeed = errors.New("cursor outside the home feed")
+
+// pushToFeed adds a post to a home feed only when it is already
+// materialized, cold feeds are built from the database on first read. The
+// sentinel at rank 0 is kept when the feed is trimmed.
+var pushToFeed = redis.NewScript(`
+if redis.call("ZSCORE", KEYS[1], ARGV[4]) then
+	redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+	redis.call("ZREMRANGEBYRANK", KEYS[1], 1, -tonumber(ARGV[3]) - 1)
+end
+return 1
+`)
+
+func feedMember(postID uint) string {
+	return fmt.Sprintf("%020d", postID)
+}
+
+func feedScore(post *models.Post) float64 {
+	if post.PublishAt == nil {
+		return float64(time.Now().UnixMilli())
+	}
+	return float64(post.PublishAt.UnixMilli())
+}
+
+func homeFeedKey(userID uint) string {
+	return "feed-home-" + strconv.Itoa(int(userID))
+}
+
+// InvalidateHomeFeed drops the materialized home feeds of the users, they
+// are rebuilt on the next read.
+func InvalidateHomeFeed(userIDs ...uint) {
+	if len(userIDs) == 0 {
+		return
+	}
+	keys := []string{}
+	for _, userID := range userIDs {
+		keys = append(keys, homeFeedKey(userID))
+	}
+	err := db.DefaultCache.Del(context.Background(), keys...).Err()
+	if err != nil {
+		log.Error("Error invalidating home feed", err)
+	}
+}
+
+// homeScope restricts posts to the ones written by the user, by people the
+// user follows or by specialists sharing one of the user's expertise tags,
+// plus the posts tagged with a followed tag or one of those expertise tags.
+func homeScope(userID uint) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(
+			"posts.author_id = @user OR "+
+				"posts.author_id IN (SELECT followee_id FROM follows WHERE follower_id = @user) OR "+
+				"posts.author_id IN (SELECT b.user_id FROM user_expertises a JOIN user_expertises b ON b.tag = a.tag WHERE a.user_id = @user) OR "+
+				"posts.id IN (SELECT post_tags.post_id FROM post_tags JOIN tags ON tags.id = post_tags.tag_id "+
+				"WHERE post_tags.tag_id IN (SELECT tag_id FROM tag_follows WHERE user_id = @user) "+
+				"OR tags.name IN (SELECT tag FROM user_expertises WHERE user_id = @user))",
+			sql.Named("user", userID),
+		)
+	}
+}
+
+// homeAudience is the inverse of homeScope: every user whose home feed
+// should receive the post, narrowed down by the post visibility.
+func homeAudience(post *models.Post) ([]uint, error) {
+	ids := []uint{}
+	switch post.Visibility {
+	case models.PostVisibilityPrivate:
+		return []uint{post.AuthorId}, nil
+	case models.PostVisibilityFollowers:
+		err := db.DefaultClient.Raw(
+			"SELECT CAST(@author AS bigint) UNION SELECT follower_id FROM follows WHERE followee_id = @author",
+			sql.Named("author", post.AuthorId),
+		).Scan(&ids).Error
+		return ids, err
+	case models.PostVisibilityChat:
+		err := db.DefaultClient.
+			Model(&models.ChatUser{}).
+			Where("chat_id = ?", post.ChatId).
+			Pluck("user_id", &ids).Error
+		return ids, err
+	}
+
+	err := db.DefaultClient.Raw(
+		"SELECT CAST(@author AS bigint) UNION "+
+			"SELECT follower_id FROM follows WHERE followee_id = @author UNION "+
+			"SELECT b.user_id FROM user_expertises a JOIN user_expertises b ON b.tag = a.tag WHERE a.user_id = @author UNION "+
+			"SELECT user_id FROM tag_follows WHERE tag_id IN (SELECT tag_id FROM post_tags WHERE post_id = @post) UNION "+
+			"SELECT user_id FROM user_expertises WHERE tag IN "+
+			"(SELECT tags.name FROM post_tags JOIN tags ON tags.id = post_tags.tag_id WHERE post_tags.post_id = @post)",
+		sql.Named("author", post.AuthorId), sql.Named("post", post.ID),
+	).Scan(&ids).Error
+	return ids, err
+}
+
+func pushToHomeFeeds(post *models.Post) {
+	audience, err := homeAudience(post)
+	if err != nil {
+		log.Error("Error getting home feed audience", err)
+		return
+	}
+
+	// Scripts queued in a pipeline can not fall back to EVAL, so the script
+	// is loaded first and run by its hash.
+	ctx := context.Background()
+	if err := pushToFeed.Load(ctx, db.DefaultCache).Err(); err != nil {
+		log.Error("Error loading home feed script", err)
+		return
+	}
+
+	pipe := db.DefaultCache.Pipeline()
+	for _, userID := range audience {
+		pushToFeed.EvalSha(
+			ctx, pipe, []string{homeFeedKey(userID)},
+			feedScore(post), feedMember(post.ID), homeFeedSize, feedSentinel,
+		)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Error("Error pushing post to home feeds", err)
+	}
+}
+
+func warmHomeFeed(userID uint) error {
+	recent := []*models.Post{}
+	err := db.DefaultClient.
+		Model(&models.Post{}).
+		Select("posts.id", "posts.publish_at").
+		Scopes(publishedScope, homeScope(userID)).
+		Order("posts.publish_at DESC, posts.id DESC").
+		Limit(homeFeedSize).
+		Find(&recent).Error
+	if err != nil {
+		return err
+	}
+
+	members := []redis.Z{{Score: 0, Member: feedSentinel}}
+	for _, post := range recent {
+		members = append(members, redis.Z{Score: feedScore(post), Member: feedMember(post.ID)})
+	}
+
+	key := homeFeedKey(userID)
+	pipe := db.DefaultCache.TxPipeline()
+	pipe.Del(context.Background(), key)
+	pipe.ZAdd(context.Background(), key, members...)
+	pipe.Expire(context.Background(), key, homeFeedTTL)
+	_, err = pipe.Exec(context.Background())
+	return err
+}
+
+// homeFeedIDs reads a page of post ids from the materialized home feed,
+// building it first when it is cold. Pages are located by the rank of the
+// cursor post, errOutsideFeed is returned when it is not in the feed.
+func homeFeedIDs(userID uint, cursor utils.Cursor) ([]uint, error) {
+	ctx := context.Background()
+	key := homeFeedKey(userID)
+
+	err := db.DefaultCache.ZScore(ctx, key, feedSentinel).Err()
+	if err == redis.Nil {
+		err = warmHomeFeed(userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	start, stop := int64(0), int64(cursor.Limit-1)
+	if cursor.Before > 0 || cursor.After > 0 {
+		anchor := cursor.Before
+		if cursor.Reversed() {
+			anchor = cursor.After
+		}
+		rank, err := db.DefaultCache.ZRevRank(ctx, key, feedMember(anchor)).Result()
+		if err == redis.Nil {
+			return nil, errOutsideFeed
+		}
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Reversed() {
+			start, stop = max(rank-int64(cursor.Limit), 0), rank-1
+		} else {
+			start, stop = rank+1, rank+int64(cursor.Limit)
+		}
+	}
+	if stop < start {
+		return []uint{}, nil
+	}
+
+	members, err := db.DefaultCache.ZRevRange(ctx, key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []uint{}
+	for _, member := range members {
+		if member == feedSentinel {
+			continue
+		}
+		id, _ := strconv.Atoi(member)
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func (h *PostsRouter) home(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	cursor := utils.ParseCursor(c)
+	ids, err := homeFeedIDs(session.ID, cursor)
+	if err != nil {
+		if err != errOutsideFeed {
+			log.Error("Error reading home feed, falling back to database", err)
+		}
+		posts, err := FindPosts(session.ID, cursor, homeScope(session.ID))
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "Internal server error",
+			})
+			return
+		}
+		c.JSON(200, posts)
+		return
+	}
+
+	posts := []*Post{}
+	if len(ids) > 0 {
+		posts, err = FindPosts(session.ID, utils.Cursor{Limit: cursor.Limit}, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("posts.id IN ?", ids)
+		})
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "Internal server error",
+			})
+			return
+		}
+	}
+
+	// Past the materialized window the rest of the feed comes from the
+	// database.
+	if len(ids) < cursor.Limit && !cursor.Reversed() {
+		next := utils.Cursor{Limit: cursor.Limit - len(posts), Before: cursor.Before}
+		if len(ids) > 0 {
+			next.Before = ids[len(ids)-1]
+		}
+		older, err := FindPosts(session.ID, next, homeScope(session.ID))
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "Internal server error",
+			})
+			return
+		}
+		posts = append(posts, older...)
+	}
+
+	c.JSON(200, posts)
+}
+
+// discoverScore ranks recent posts by engagement, decaying with age.
+const discoverScore = "((SELECT count(*) FROM reactions WHERE reactions.target_type = 'post' AND reactions.target_id = posts.id) + " +
+	"2 * (SELECT count(*) FROM comments WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL) + 1) / " +
+	"power(extract(epoch FROM now() - posts.publish_at) / 3600 + 2, 1.5)"
+
+type rankedPost struct {
+	ID    uint
+	Score float64
+}
+
+func rankDiscover(offset, limit int) ([]rankedPost, error) {
+	ranked := []rankedPost{}
+	err := db.DefaultClient.
+		Model(&models.Post{}).
+		Select("posts.id, "+discoverScore+" AS score").
+		Scopes(publishedScope).
+		Where("posts.kind <> ? AND posts.visibility = ?", models.PostKindRepost, models.PostVisibilityPublic).
+		Where("posts.publish_at > now() - interval '" + discoverFeedWindow + "'").
+		Order("score DESC, posts.id DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&ranked).Error
+	return ranked, err
+}
+
+func discoverFeedIDs(offset, limit int) ([]uint, error) {
+	ctx := context.Background()
+
+	exists, err := db.DefaultCache.Exists(ctx, discoverFeedKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		ranked, err := rankDiscover(0, discoverFeedSize)
+		if err != nil {
+			return nil, err
+		}
+		members := []redis.Z{{Score: -1, Member: feedSentinel}}
+		for _, post := range ranked {
+			members = append(members, redis.Z{Score: post.Score, Member: post.ID})
+		}
+		pipe := db.DefaultCache.TxPipeline()
+		pipe.ZAdd(ctx, discoverFeedKey, members...)
+		pipe.Expire(ctx, discoverFeedKey, discoverFeedTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	members, err := db.DefaultCache.ZRevRangeByScore(ctx, discoverFeedKey, &redis.ZRangeBy{
+		Min:    "0",
+		Max:    "+inf",
+		Offset: int64(offset),
+		Count:  int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []uint{}
+	for _, member := range members {
+		id, _ := strconv.Atoi(member)
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func (h *PostsRouter) discover(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	cursor := utils.ParseCursor(c)
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	offset = max(offset, 0)
+
+	ids, err := discoverFeedIDs(offset, cursor.Limit)
+	if err != nil {
+		log.Error("Error reading discover feed, falling back to database", err)
+		ranked, err := rankDiscover(offset, cursor.Limit)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "Internal server error",
+			})
+			return
+		}
+		ids = []uint{}
+		for _, post := range ranked {
+			ids = append(ids, post.ID)
+		}
+	}
+
+	posts := []*Post{}
+	if len(ids) > 0 {
+		posts, err = FindPosts(session.ID, utils.Cursor{Limit: len(ids)}, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("posts.id IN ?", ids)
+		})
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "Internal server error",
+			})
+			return
+		}
+	}
+
+	c.JSON(200, sortByIDs(posts, ids))
+}
+
+// sortByIDs orders posts following ids, dropping the ones not found.
+func sortByIDs(posts []*Post, ids []uint) []*Post {
+	byID := map[uint]*Post{}
+	for _, post := range posts {
+		byID[uint(post.ID)] = post
+	}
+
+	sorted := []*Post{}
+	for _, id := range ids {
+		if post, ok := byID[id]; ok {
+			sorted = append(sorted, post)
+		}
+	}
+	return sorted
+}
@@ -0,0 +1,392 @@
+package posts
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/events"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
+)
+
+// pollViewersTTL is how long a user keeps receiving live results of a
+// poll after last loading it.
+const pollViewersTTL = time.Hour
+
+type PollPayload struct {
+	Options        []string   `json:"options" validate:"min=2,max=10,dive,required,max=200"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	Anonymous      bool       `json:"anonymous"`
+	ClosesAt       *time.Time `json:"closes_at"`
+}
+
+type PollOption struct {
+	ID       uint   `json:"id"`
+	PollID   uint   `json:"-"`
+	Position int    `json:"position"`
+	Text     string `json:"text"`
+	Votes    int64  `json:"votes" gorm:"->"`
+}
+
+type Poll struct {
+	ID             uint         `json:"id"`
+	PostID         uint         `json:"post_id"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	Anonymous      bool         `json:"anonymous"`
+	ClosesAt       *time.Time   `json:"closes_at,omitempty"`
+	Closed         bool         `json:"closed" gorm:"-"`
+	Voters         int64        `json:"voters" gorm:"->"`
+	Options        []PollOption `json:"options" gorm:"-"`
+	Voted          []uint       `json:"voted" gorm:"-"`
+}
+
+type PollVote struct {
+	ID         uint      `json:"id"`
+	OptionID   uint      `json:"option_id"`
+	UserID     uint      `json:"user_id"`
+	User       User      `json:"user"`
+	CreationAt time.Time `json:"creation_at"`
+}
+
+type VotePayload struct {
+	OptionIDs []uint `json:"option_ids" validate:"min=1,max=10"`
+}
+
+type VoteErrors struct {
+	OptionIDs string `json:"option_ids,omitempty"`
+}
+
+func pollViewersKey(pollID uint) string {
+	return "poll-viewers-" + strconv.Itoa(int(pollID))
+}
+
+func pollClosed(closesAt *time.Time) bool {
+	return closesAt != nil && !closesAt.After(time.Now())
+}
+
+// pollError picks the validation message of the poll fields, options are
+// reported by index.
+func pollError(errorsMap map[string]string) string {
+	for field, message := range errorsMap {
+		if strings.HasPrefix(field, "Options") {
+			return message
+		}
+	}
+	return ""
+}
+
+// newPoll builds the poll of a post being created, it must close in the
+// future. Options are trimmed and must be non blank and distinct
+// regardless of case.
+func newPoll(payload *PollPayload) (*models.Poll, bool) {
+	if payload.ClosesAt != nil && !payload.ClosesAt.After(time.Now()) {
+		return nil, false
+	}
+
+	poll := &models.Poll{
+		MultipleChoice: payload.MultipleChoice,
+		Anonymous:      payload.Anonymous,
+		ClosesAt:       payload.ClosesAt,
+	}
+	seen := map[string]bool{}
+	for position, text := range payload.Options {
+		text = strings.TrimSpace(text)
+		if text == "" || seen[strings.ToLower(text)] {
+			return nil, false
+		}
+		seen[strings.ToLower(text)] = true
+		poll.Options = append(poll.Options, models.PollOption{
+			Position: position,
+			Text:     text,
+		})
+	}
+	return poll, true
+}
+
+// findPolls loads the polls of the given posts with their results, the
+// viewer is subscribed to live updates of every poll loaded.
+func findPolls(viewerID uint, postIDs []uint) map[uint]*Poll {
+	result := map[uint]*Poll{}
+	if len(postIDs) == 0 {
+		return result
+	}
+
+	polls := []*Poll{}
+	err := db.DefaultClient.
+		Model(&models.Poll{}).
+		Select("polls.*, (SELECT count(DISTINCT user_id) FROM poll_votes WHERE poll_votes.poll_id = polls.id) AS voters").
+		Where("polls.post_id IN ?", postIDs).
+		Find(&polls).Error
+	if err != nil {
+		log.Error("Error loading polls", err)
+		return result
+	}
+	if len(polls) == 0 {
+		return result
+	}
+
+	byID := map[uint]*Poll{}
+	pollIDs := []uint{}
+	for _, poll := range polls {
+		poll.Closed = pollClosed(poll.ClosesAt)
+		poll.Options = []PollOption{}
+		poll.Voted = []uint{}
+		byID[poll.ID] = poll
+		pollIDs = append(pollIDs, poll.ID)
+		result[poll.PostID] = poll
+	}
+
+	options := []PollOption{}
+	err = db.DefaultClient.
+		Model(&models.PollOption{}).
+		Select("poll_options.*, (SELECT count(*) FROM poll_votes WHERE poll_votes.option_id = poll_options.id) AS votes").
+		Where("poll_options.poll_id IN ?", pollIDs).
+		Order("poll_options.position").
+		Find(&options).Error
+	if err != nil {
+		log.Error("Error loading poll options", err)
+	}
+	for _, option := range options {
+		byID[option.PollID].Options = append(byID[option.PollID].Options, option)
+	}
+
+	if viewerID == 0 {
+		return result
+	}
+
+	votes := []models.PollVote{}
+	err = db.DefaultClient.
+		Select("poll_id", "option_id").
+		Where("poll_id IN ? AND user_id = ?", pollIDs, viewerID).
+		Find(&votes).Error
+	if err != nil {
+		log.Error("Error loading poll votes", err)
+	}
+	for _, vote := range votes {
+		byID[vote.PollId].Voted = append(byID[vote.PollId].Voted, vote.OptionId)
+	}
+
+	ctx := context.Background()
+	pipe := db.DefaultCache.Pipeline()
+	for _, poll := range polls {
+		if poll.Closed {
+			continue
+		}
+		pipe.SAdd(ctx, pollViewersKey(poll.ID), viewerID)
+		pipe.Expire(ctx, pollViewersKey(poll.ID), pollViewersTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Error("Error subscribing to poll updates", err)
+	}
+
+	return result
+}
+
+// broadcastPoll sends the current results of a poll to the users that
+// recently loaded it.
+func broadcastPoll(poll *models.Poll) {
+	results, ok := findPolls(0, []uint{poll.PostId})[poll.PostId]
+	if !ok {
+		return
+	}
+
+	viewers, err := db.DefaultCache.SMembers(context.Background(), pollViewersKey(poll.ID)).Result()
+	if err != nil {
+		log.Error("Error loading poll viewers", err)
+		return
+	}
+	for _, viewer := range viewers {
+		userID, _ := strconv.Atoi(viewer)
+		events.DefaultEventsRouter.Send(uint(userID), "poll_updated", results)
+	}
+}
+
+// findPoll loads the poll of a post the viewer can see, answering the
+// request when there is none.
+func findPoll(c *gin.Context, viewerID uint) (*models.Poll, bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if _, ok := findVisible(viewerID, uint(id)); !ok {
+		c.JSON(404, gin.H{
+			"message": "Post not found",
+		})
+		return nil, false
+	}
+
+	poll := &models.Poll{}
+	err := db.DefaultClient.
+		Preload("Options").
+		Where(&models.Poll{PostId: uint(id)}).
+		First(poll).Error
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "Poll not found",
+		})
+		return nil, false
+	}
+	return poll, true
+}
+
+func (h *PostsRouter) vote(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := &VotePayload{}
+	err = c.ShouldBind(payload)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "Invalid payload",
+		})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, VoteErrors{
+			OptionIDs: errorsMap["OptionIDs"],
+		})
+		return
+	}
+
+	poll, ok := findPoll(c, session.ID)
+	if !ok {
+		return
+	}
+	if pollClosed(poll.ClosesAt) {
+		c.JSON(403, gin.H{
+			"message": "Poll is closed",
+		})
+		return
+	}
+
+	slices.Sort(payload.OptionIDs)
+	optionIDs := slices.Compact(payload.OptionIDs)
+	if !poll.MultipleChoice && len(optionIDs) > 1 {
+		c.JSON(http.StatusBadRequest, VoteErrors{
+			OptionIDs: "Invalid field!",
+		})
+		return
+	}
+	votes := []models.PollVote{}
+	for _, optionID := range optionIDs {
+		valid := slices.ContainsFunc(poll.Options, func(option models.PollOption) bool {
+			return option.ID == optionID
+		})
+		if !valid {
+			c.JSON(http.StatusBadRequest, VoteErrors{
+				OptionIDs: "Invalid field!",
+			})
+			return
+		}
+		votes = append(votes, models.PollVote{PollId: poll.ID, OptionId: optionID, UserId: session.ID})
+	}
+
+	// A vote replaces the previous choice of the user, the advisory lock
+	// keeps concurrent votes of the same user from adding up.
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("SELECT pg_advisory_xact_lock(CAST(? AS int), CAST(? AS int))", poll.ID, session.ID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where(&models.PollVote{PollId: poll.ID, UserId: session.ID}).
+			Delete(&models.PollVote{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&votes).Error
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	broadcastPoll(poll)
+
+	c.JSON(201, gin.H{"message": "Voted"})
+}
+
+func (h *PostsRouter) unvote(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	poll, ok := findPoll(c, session.ID)
+	if !ok {
+		return
+	}
+	if pollClosed(poll.ClosesAt) {
+		c.JSON(403, gin.H{
+			"message": "Poll is closed",
+		})
+		return
+	}
+
+	err = db.DefaultClient.
+		Where(&models.PollVote{PollId: poll.ID, UserId: session.ID}).
+		Delete(&models.PollVote{}).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	broadcastPoll(poll)
+
+	c.JSON(200, gin.H{"message": "Vote removed"})
+}
+
+func (h *PostsRouter) getVotes(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	poll, ok := findPoll(c, session.ID)
+	if !ok {
+		return
+	}
+	if poll.Anonymous {
+		c.JSON(403, gin.H{
+			"message": "Poll is anonymous",
+		})
+		return
+	}
+
+	tx := db.DefaultClient.
+		Model(&models.PollVote{}).
+		Preload("User").
+		Where(&models.PollVote{PollId: poll.ID})
+	if optionID, _ := strconv.Atoi(c.Query("option_id")); optionID > 0 {
+		tx = tx.Where(&models.PollVote{OptionId: uint(optionID)})
+	}
+
+	cursor := utils.ParseCursor(c)
+	votes := []*PollVote{}
+	err = cursor.Apply(tx, "poll_votes.id").Find(&votes).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if cursor.Reversed() {
+		slices.Reverse(votes)
+	}
+
+	c.JSON(200, votes)
+}
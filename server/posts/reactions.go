@@ -0,0 +1,338 @@
+package posts
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/notifications"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm/clause"
+)
+
+// ReactionCounts is the number of reactions of each type, it is scanned
+// from the json object aggregated by the feed queries.
+type ReactionCounts map[string]int64
+
+func (r *ReactionCounts) Scan(value interface{}) error {
+	*r = ReactionCounts{}
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	}
+	return nil
+}
+
+func (r ReactionCounts) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// reactionsSelect aggregates the reactions of the rows of table whose type
+// is targetType, it expects the viewer as the named argument @viewer.
+func reactionsSelect(table, targetType string) string {
+	target := "reactions.target_type = '" + targetType + "' AND reactions.target_id = " + table + ".id"
+	return "(SELECT count(*) FROM reactions WHERE " + target + " AND reactions.type = 'like') AS likes, " +
+		"EXISTS (SELECT 1 FROM reactions WHERE " + target + " AND reactions.type = 'like' AND reactions.author_id = @viewer) AS liked, " +
+		"(SELECT reactions.type FROM reactions WHERE " + target + " AND reactions.author_id = @viewer) AS reaction, " +
+		"(SELECT json_object_agg(counts.type, counts.total) FROM " +
+		"(SELECT reactions.type, count(*) AS total FROM reactions WHERE " + target + " GROUP BY reactions.type) counts) AS reactions"
+}
+
+type Reaction struct {
+	ID         uint      `json:"id"`
+	Type       string    `json:"type"`
+	AuthorID   uint      `json:"author_id"`
+	Author     User      `json:"author"`
+	CreationAt time.Time `json:"creation_at"`
+}
+
+type ReactPayload struct {
+	Type string `json:"type" validate:"required"`
+}
+
+type ReactErrors struct {
+	Type string `json:"type,omitempty"`
+}
+
+func (h *PostsRouter) likePost(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	h.react(c, session, models.ReactionTargetPost, uint(id), "like")
+}
+
+func (h *PostsRouter) unlikePost(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	h.unreact(c, session, models.ReactionTargetPost, uint(id), "like")
+}
+
+func (h *PostsRouter) reactPost(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := &ReactPayload{}
+	if !bindReaction(c, payload) {
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	h.react(c, session, models.ReactionTargetPost, uint(id), payload.Type)
+}
+
+func (h *PostsRouter) unreactPost(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	h.unreact(c, session, models.ReactionTargetPost, uint(id), "")
+}
+
+func (h *PostsRouter) getPostReactions(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if _, ok := findVisible(session.ID, uint(id)); !ok {
+		c.JSON(404, gin.H{
+			"message": "Post not found",
+		})
+		return
+	}
+	h.getReactions(c, models.ReactionTargetPost, uint(id))
+}
+
+func (h *PostsRouter) reactComment(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := &ReactPayload{}
+	if !bindReaction(c, payload) {
+		return
+	}
+
+	commentID, ok := commentOfPost(c, session.ID)
+	if !ok {
+		c.JSON(404, gin.H{
+			"message": "Comment not found",
+		})
+		return
+	}
+	h.react(c, session, models.ReactionTargetComment, commentID, payload.Type)
+}
+
+func (h *PostsRouter) unreactComment(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	commentID, _ := strconv.Atoi(c.Param("commentId"))
+	h.unreact(c, session, models.ReactionTargetComment, uint(commentID), "")
+}
+
+func (h *PostsRouter) getCommentReactions(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	commentID, ok := commentOfPost(c, session.ID)
+	if !ok {
+		c.JSON(404, gin.H{
+			"message": "Comment not found",
+		})
+		return
+	}
+	h.getReactions(c, models.ReactionTargetComment, commentID)
+}
+
+func bindReaction(c *gin.Context, payload *ReactPayload) bool {
+	err := c.ShouldBind(payload)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "Invalid payload",
+		})
+		return false
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, ReactErrors{
+			Type: errorsMap["Type"],
+		})
+		return false
+	}
+	if !slices.Contains(models.ReactionTypes, payload.Type) {
+		c.JSON(http.StatusBadRequest, ReactErrors{
+			Type: "Invalid field!",
+		})
+		return false
+	}
+	return true
+}
+
+// commentOfPost checks that the comment in the path belongs to the post in
+// the path and that the viewer can see that post.
+func commentOfPost(c *gin.Context, viewerID uint) (uint, bool) {
+	postID, _ := strconv.Atoi(c.Param("id"))
+	commentID, _ := strconv.Atoi(c.Param("commentId"))
+	if _, ok := findVisible(viewerID, uint(postID)); !ok {
+		return uint(commentID), false
+	}
+
+	count := int64(0)
+	db.DefaultClient.
+		Model(&models.Comment{}).
+		Where("comments.id = ? AND comments.post_id = ?", commentID, postID).
+		Count(&count)
+	return uint(commentID), count > 0
+}
+
+func (h *PostsRouter) react(c *gin.Context, session *utils.User, targetType string, targetID uint, reactionType string) {
+	authorID, ok := targetAuthor(session.ID, targetType, targetID)
+	if !ok {
+		c.JSON(404, gin.H{
+			"message": "Not found",
+		})
+		return
+	}
+
+	reaction := &models.Reaction{
+		TargetType: targetType,
+		TargetId:   targetID,
+		AuthorId:   session.ID,
+		Type:       reactionType,
+		UpdatedAt:  time.Now(),
+	}
+	result := db.DefaultClient.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(reaction)
+	if result.Error != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	// Only a new reaction notifies the author, switching its type does not.
+	if result.RowsAffected > 0 {
+		notifications.Notify(authorID, session.ID, models.NotificationReaction, targetType, targetID)
+	} else {
+		err := db.DefaultClient.
+			Model(&models.Reaction{}).
+			Where("target_type = ? AND target_id = ? AND author_id = ?", targetType, targetID, session.ID).
+			Updates(map[string]interface{}{"type": reactionType, "updated_at": time.Now()}).Error
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "Internal server error",
+			})
+			return
+		}
+	}
+
+	c.JSON(201, gin.H{"message": "Reaction saved"})
+}
+
+// targetAuthor returns the author of the post or comment being reacted to,
+// as long as the viewer can see the post.
+func targetAuthor(viewerID uint, targetType string, targetID uint) (uint, bool) {
+	switch targetType {
+	case models.ReactionTargetPost:
+		post, ok := findVisible(viewerID, targetID)
+		if !ok {
+			return 0, false
+		}
+		return post.AuthorId, true
+	case models.ReactionTargetComment:
+		comment := &models.Comment{}
+		err := db.DefaultClient.
+			Where("comments.id = ?", targetID).
+			First(comment).Error
+		if err != nil {
+			return 0, false
+		}
+		if _, ok := findVisible(viewerID, comment.PostId); !ok {
+			return 0, false
+		}
+		return comment.AuthorId, true
+	}
+	return 0, false
+}
+
+// unreact removes the reaction of the user to the target, only when it is of
+// reactionType unless that is empty.
+func (h *PostsRouter) unreact(c *gin.Context, session *utils.User, targetType string, targetID uint, reactionType string) {
+	tx := db.DefaultClient.
+		Where("target_type = ? AND target_id = ? AND author_id = ?", targetType, targetID, session.ID)
+	if reactionType != "" {
+		tx = tx.Where("type = ?", reactionType)
+	}
+	err := tx.Delete(&models.Reaction{}).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Reaction removed"})
+}
+
+func (h *PostsRouter) getReactions(c *gin.Context, targetType string, targetID uint) {
+	tx := db.DefaultClient.
+		Model(&models.Reaction{}).
+		Preload("Author").
+		Where(&models.Reaction{TargetType: targetType, TargetId: targetID})
+	if reactionType := c.Query("type"); reactionType != "" {
+		tx = tx.Where(&models.Reaction{Type: reactionType})
+	}
+
+	cursor := utils.ParseCursor(c)
+	reactions := []*Reaction{}
+	err := cursor.Apply(tx, "reactions.id").Find(&reactions).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if cursor.Reversed() {
+		slices.Reverse(reactions)
+	}
+
+	c.JSON(200, reactions)
+}
@@ -0,0 +1,151 @@
+package posts
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/notifications"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// repostsSelect counts the live reposts and quotes of each post and tells
+// whether the viewer reposted it, it expects the viewer as @viewer.
+const repostsSelect = "(SELECT count(*) FROM posts reposts WHERE reposts.repost_of_id = posts.id AND reposts.kind = 'repost' AND reposts.deleted_at IS NULL) AS reposts, " +
+	"(SELECT count(*) FROM posts quotes WHERE quotes.repost_of_id = posts.id AND quotes.kind = 'quote' AND quotes.status = 'published' AND quotes.deleted_at IS NULL) AS quotes, " +
+	"EXISTS (SELECT 1 FROM posts reposts WHERE reposts.repost_of_id = posts.id AND reposts.kind = 'repost' AND reposts.deleted_at IS NULL AND reposts.author_id = @viewer) AS reposted"
+
+// findOriginal loads the public post being reposted or quoted, sharing a
+// repost shares the post it points to.
+func findOriginal(id uint) (*models.Post, bool) {
+	original := &models.Post{}
+	err := db.DefaultClient.
+		Scopes(publishedScope).
+		Where("posts.id = ? AND posts.visibility = ?", id, models.PostVisibilityPublic).
+		First(original).Error
+	if err != nil {
+		return nil, false
+	}
+	if original.Kind == models.PostKindRepost && original.RepostOfId != nil {
+		return findOriginal(*original.RepostOfId)
+	}
+	return original, true
+}
+
+// hydrateOriginals embeds the post each repost or quote points to and
+// returns the ones loaded. Originals the viewer can no longer read, because
+// they were deleted, hidden, unpublished or narrowed, become a tombstone
+// holding only their id.
+func hydrateOriginals(viewerID uint, posts []*Post) []*Post {
+	ids := []uint{}
+	for _, post := range posts {
+		if post.RepostOfID != nil {
+			ids = append(ids, *post.RepostOfID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	originals := []*Post{}
+	err := feedQuery(viewerID).
+		Scopes(publishedScope, visibleScope(viewerID)).
+		Where("posts.id IN ?", ids).
+		Find(&originals).Error
+	if err != nil {
+		log.Error("Error loading reposted posts", err)
+		return nil
+	}
+
+	byID := map[uint]*Post{}
+	for _, original := range originals {
+		byID[uint(original.ID)] = original
+	}
+	for _, post := range posts {
+		if post.RepostOfID == nil {
+			continue
+		}
+		post.RepostOf = byID[*post.RepostOfID]
+		if post.RepostOf == nil {
+			post.RepostOf = &Post{ID: int(*post.RepostOfID)}
+		}
+	}
+	return originals
+}
+
+func (h *PostsRouter) repost(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	original, ok := findOriginal(uint(id))
+	if !ok {
+		c.JSON(404, gin.H{
+			"message": "Post not found",
+		})
+		return
+	}
+
+	now := time.Now()
+	post := &models.Post{
+		AuthorId:   session.ID,
+		Kind:       models.PostKindRepost,
+		RepostOfId: &original.ID,
+		Status:     models.PostStatusPublished,
+		PublishAt:  &now,
+	}
+	result := db.DefaultClient.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(post)
+	if result.Error != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(200, gin.H{"message": "Already reposted"})
+		return
+	}
+
+	pushToHomeFeeds(post)
+	notifications.Notify(original.AuthorId, session.ID, models.NotificationRepost, models.MentionSourcePost, original.ID)
+
+	c.JSON(201, gin.H{"message": "Reposted"})
+}
+
+func (h *PostsRouter) unrepost(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	err = db.DefaultClient.
+		Where("author_id = ? AND repost_of_id = ? AND kind = ?", session.ID, id, models.PostKindRepost).
+		Delete(&models.Post{}).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Repost removed"})
+}
+
+// deleteReposts removes the plain reposts of a deleted post, quotes are
+// kept and show the original as deleted.
+func deleteReposts(tx *gorm.DB, postID uint) error {
+	return tx.
+		Where("repost_of_id = ? AND kind = ?", postID, models.PostKindRepost).
+		Delete(&models.Post{}).Error
+}
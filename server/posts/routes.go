@@ -1,34 +1,66 @@
 package posts
 
 import (
+	"database/sql"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator"
 	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/logger"
 	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/contentfilter"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/mentions"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/previews"
 	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
 )
 
+var log = logger.SetupLogger()
+
 type PostsRouter struct{}
 
 func SetupApiRoutes(g *gin.RouterGroup) {
 	h := &PostsRouter{}
 
 	g.GET("", h.find)
+	g.GET("/home", h.home)
+	g.GET("/discover", h.discover)
+	g.GET("/drafts", h.drafts)
 	g.GET("/:id", h.findOne)
 	g.POST("", h.create)
 	g.PATCH("/:id", h.update)
 	g.DELETE("/:id", h.delete)
+	g.GET("/:id/revisions", h.getRevisions)
+	g.POST("/:id/publish", h.publish)
+	g.PUT("/:id/schedule", h.schedule)
+	g.DELETE("/:id/schedule", h.unschedule)
+	g.POST("/:id/repost", h.repost)
+	g.DELETE("/:id/repost", h.unrepost)
+	g.PUT("/:id/audience", h.setAudience)
+	g.GET("/:id/poll/votes", h.getVotes)
+	g.POST("/:id/poll/votes", h.vote)
+	g.DELETE("/:id/poll/votes", h.unvote)
+	g.POST("/:id/bookmark", h.bookmark)
+	g.DELETE("/:id/bookmark", h.unbookmark)
 
 	g.POST("/:id/like", h.likePost)
 	g.DELETE("/:id/like", h.unlikePost)
+	g.GET("/:id/reactions", h.getPostReactions)
+	g.POST("/:id/reactions", h.reactPost)
+	g.DELETE("/:id/reactions", h.unreactPost)
 
 	g.POST("/:id/comment", h.createComment)
 	g.GET("/:id/comments", h.getComments)
+	g.GET("/:id/comments/:commentId/replies", h.getReplies)
+	g.PATCH("/:id/comment/:commentId", h.updateComment)
 	g.DELETE("/:id/comment/:commentId", h.deleteComment)
+	g.GET("/:id/comment/:commentId/reactions", h.getCommentReactions)
+	g.POST("/:id/comment/:commentId/reactions", h.reactComment)
+	g.DELETE("/:id/comment/:commentId/reactions", h.unreactComment)
 }
 
 type User struct {
@@ -45,34 +77,50 @@ type User struct {
 }
 
 type Post struct {
-	ID         int        `json:"id"`
-	Content    string     `json:"content" validate:"required"`
-	AuthorID   int        `json:"author_id"`
-	Author     User       `json:"author"`
-	Likes      int64      `json:"likes" gorm:"-"`
-	Liked      bool       `json:"liked" gorm:"-"`
-	Comments   int64      `json:"comments" gorm:"-"`
-	CreationAt time.Time  `json:"creation_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	ID          int                `json:"id"`
+	Content     string             `json:"content" validate:"required"`
+	AuthorID    int                `json:"author_id"`
+	Author      User               `json:"author"`
+	Kind        string             `json:"kind"`
+	RepostOfID  *uint              `json:"repost_of_id,omitempty"`
+	RepostOf    *Post              `json:"repost_of,omitempty" gorm:"-"`
+	Reposts     int64              `json:"reposts" gorm:"->"`
+	Quotes      int64              `json:"quotes" gorm:"->"`
+	Reposted    bool               `json:"reposted" gorm:"->"`
+	Bookmarked  bool               `json:"bookmarked" gorm:"->"`
+	Status      string             `json:"status"`
+	PublishAt   *time.Time         `json:"publish_at,omitempty"`
+	Visibility  string             `json:"visibility"`
+	ChatID      *uint              `json:"chat_id,omitempty"`
+	Likes       int64              `json:"likes" gorm:"->"`
+	Liked       bool               `json:"liked" gorm:"->"`
+	Reaction    *string            `json:"reaction" gorm:"->"`
+	Reactions   ReactionCounts     `json:"reactions" gorm:"->"`
+	Comments    int64              `json:"comments" gorm:"->"`
+	Edited      bool               `json:"edited"`
+	EditedAt    *time.Time         `json:"edited_at,omitempty"`
+	Attachments []PostAttachment   `json:"attachments"`
+	Poll        *Poll              `json:"poll,omitempty" gorm:"-"`
+	Mentions    []mentions.Mention `json:"mentions" gorm:"-"`
+	Previews    []previews.Preview `json:"previews" gorm:"-"`
+	CreationAt  time.Time          `json:"creation_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	DeletedAt   *time.Time         `json:"deleted_at,omitempty"`
 }
 
 func (h *PostsRouter) findOne(c *gin.Context) {
-	session, err := utils.ValidateSession(c)
-	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+	viewerID, ok := viewerOf(c)
+	if !ok {
 		return
 	}
 
 	id, _ := strconv.Atoi(c.Param("id"))
 
 	post := &Post{}
-	err = db.DefaultClient.
-		Model(&models.Post{}).
-		Preload("Author").
-		Where(models.Post{ID: uint(id)}).
+	err := feedQuery(viewerID).
+		Scopes(visibleScope(viewerID)).
+		Where("posts.id = ?", id).
+		Where("(posts.status = ? AND posts.hidden = false) OR posts.author_id = ?", models.PostStatusPublished, viewerID).
 		First(post).Error
 	if err != nil {
 		c.JSON(404, gin.H{
@@ -80,114 +128,135 @@ func (h *PostsRouter) findOne(c *gin.Context) {
 		})
 		return
 	}
+	hydratePosts(viewerID, []*Post{post})
 
-	count := int64(0)
-	err = db.DefaultClient.
-		Model(&models.Like{}).
-		Where(models.Like{PostId: uint(post.ID)}).
-		Count(&count).Error
-	if err != nil {
-		c.JSON(500, gin.H{
-			"message": "Internal server error",
-		})
+	c.JSON(200, post)
+}
+
+func (h *PostsRouter) find(c *gin.Context) {
+	viewerID, ok := viewerOf(c)
+	if !ok {
 		return
 	}
-	post.Likes = count
 
-	db.DefaultClient.
-		Model(&models.Like{}).
-		Where(&models.Like{PostId: uint(post.ID), AuthorId: session.ID}).
-		Limit(1).
-		Count(&count)
-	post.Liked = count == 1
+	scopes := []func(*gorm.DB) *gorm.DB{}
+	if q := c.Query("q"); q != "" {
+		scopes = append(scopes, searchScope(q))
+	}
 
-	err = db.DefaultClient.
-		Model(&models.Comment{}).
-		Where(models.Comment{PostId: uint(post.ID)}).
-		Count(&count).Error
+	posts, err := FindPosts(viewerID, utils.ParseCursor(c), scopes...)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "Internal server error",
 		})
 		return
 	}
-	post.Comments = count
 
-	c.JSON(200, post)
+	c.JSON(200, posts)
 }
 
-func (h *PostsRouter) find(c *gin.Context) {
-	session, err := utils.ValidateSession(c)
-	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
-		return
-	}
-
-	posts := &[]*Post{}
-	err = db.DefaultClient.
+// feedQuery selects posts along with their reaction and comment counts and
+// the viewer's reaction, so no extra query is needed per post.
+func feedQuery(viewerID uint) *gorm.DB {
+	return db.DefaultClient.
 		Model(&models.Post{}).
+		Select(
+			"posts.*, "+
+				reactionsSelect("posts", models.ReactionTargetPost)+", "+
+				repostsSelect+", "+
+				"EXISTS (SELECT 1 FROM bookmarks WHERE bookmarks.post_id = posts.id AND bookmarks.user_id = @viewer) AS bookmarked, "+
+				"(SELECT count(*) FROM comments WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL AND comments.hidden = false) AS comments",
+			sql.Named("viewer", viewerID),
+		).
 		Preload("Author").
-		Order("creation_at DESC").
-		Find(posts).Error
+		Preload("Attachments", preloadAttachments)
+}
+
+// searchScope matches the post content and the text extracted from its
+// attachments.
+func searchScope(q string) func(*gorm.DB) *gorm.DB {
+	pattern := "%" + q + "%"
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(
+			"posts.content ILIKE ? OR EXISTS (SELECT 1 FROM post_attachments WHERE post_attachments.post_id = posts.id AND post_attachments.deleted_at IS NULL AND post_attachments.text ILIKE ?)",
+			pattern, pattern,
+		)
+	}
+}
+
+// FindPosts loads a page of the posts the viewer is allowed to see, scopes
+// narrow down which posts are listed.
+func FindPosts(viewerID uint, cursor utils.Cursor, scopes ...func(*gorm.DB) *gorm.DB) ([]*Post, error) {
+	tx := feedQuery(viewerID).Scopes(publishedScope, visibleScope(viewerID)).Scopes(scopes...)
+	return findPage(viewerID, applyPublishCursor(tx, cursor), cursor)
+}
+
+// findPage loads a page of posts already ordered by its cursor.
+func findPage(viewerID uint, tx *gorm.DB, cursor utils.Cursor) ([]*Post, error) {
+	posts := []*Post{}
+	err := tx.Find(&posts).Error
 	if err != nil {
-		c.JSON(500, gin.H{
-			"message": "Internal server error",
-		})
-		return
+		return nil, err
 	}
-	for _, post := range *posts {
-		count := int64(0)
-		err = db.DefaultClient.
-			Model(&models.Like{}).
-			Where(models.Like{PostId: uint(post.ID)}).
-			Count(&count).Error
-		if err != nil {
-			c.JSON(500, gin.H{
-				"message": "Internal server error",
-			})
-			return
-		}
-		post.Likes = count
-
-		db.DefaultClient.
-			Model(&models.Like{}).
-			Where(&models.Like{PostId: uint(post.ID), AuthorId: session.ID}).
-			Limit(1).
-			Count(&count)
-		post.Liked = count == 1
-
-		err = db.DefaultClient.
-			Model(&models.Comment{}).
-			Where(models.Comment{PostId: uint(post.ID)}).
-			Count(&count).Error
-		if err != nil {
-			c.JSON(500, gin.H{
-				"message": "Internal server error",
-			})
-			return
-		}
-		post.Comments = count
+	if cursor.Reversed() {
+		slices.Reverse(posts)
 	}
+	hydratePosts(viewerID, posts)
 
-	c.JSON(200, posts)
+	return posts, nil
+}
+
+// hydratePosts loads the data of the posts that does not come from the
+// feed query, and counts them as viewed.
+func hydratePosts(viewerID uint, posts []*Post) {
+	all := append(hydrateOriginals(viewerID, posts), posts...)
+
+	ids := []uint{}
+	for _, post := range all {
+		ids = append(ids, uint(post.ID))
+	}
+
+	postMentions := mentions.Find(models.MentionSourcePost, ids)
+	postPreviews := previews.Find(models.MentionSourcePost, ids)
+	polls := findPolls(viewerID, ids)
+	for _, post := range all {
+		post.Mentions = postMentions[uint(post.ID)]
+		post.Previews = postPreviews[uint(post.ID)]
+		post.Poll = polls[uint(post.ID)]
+	}
+	trackViews(viewerID, posts)
+}
+
+type CreatePayload struct {
+	Content     string              `json:"content" validate:"required,max=1000"`
+	Attachments []AttachmentPayload `json:"attachments" validate:"max=10,dive"`
+	Poll        *PollPayload        `json:"poll"`
+	QuoteOfID   *uint               `json:"quote_of_id"`
+	Visibility  string              `json:"visibility" validate:"omitempty,oneof=public followers private chat"`
+	ChatID      *uint               `json:"chat_id"`
+	Status      string              `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt   *time.Time          `json:"publish_at"`
 }
 
 type CreateErrors struct {
-	Content string `json:"content,omitempty"`
+	Content     string `json:"content,omitempty"`
+	Attachments string `json:"attachments,omitempty"`
+	Poll        string `json:"poll,omitempty"`
+	QuoteOfID   string `json:"quote_of_id,omitempty"`
+	Visibility  string `json:"visibility,omitempty"`
+	ChatID      string `json:"chat_id,omitempty"`
+	Status      string `json:"status,omitempty"`
+	PublishAt   string `json:"publish_at,omitempty"`
 }
 
 func (h *PostsRouter) create(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
-	payload := &Post{}
+	payload := &CreatePayload{}
 	err = c.ShouldBind(payload)
 	if err != nil {
 		c.JSON(400, gin.H{
@@ -201,39 +270,164 @@ func (h *PostsRouter) create(c *gin.Context) {
 		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
 
 		customErrors := CreateErrors{
-			Content: errorsMap["Content"],
+			Content:     errorsMap["Content"],
+			Attachments: errorsMap["Attachments"],
+			Poll:        pollError(errorsMap),
+			Visibility:  errorsMap["Visibility"],
+			Status:      errorsMap["Status"],
 		}
 		c.JSON(http.StatusBadRequest, customErrors)
 		return
 	}
 
+	if payload.Status == "" {
+		payload.Status = models.PostStatusPublished
+	}
+	switch payload.Status {
+	case models.PostStatusScheduled:
+		if payload.PublishAt == nil || !payload.PublishAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, CreateErrors{
+				PublishAt: "Invalid field!",
+			})
+			return
+		}
+	case models.PostStatusPublished:
+		now := time.Now()
+		payload.PublishAt = &now
+	default:
+		payload.PublishAt = nil
+	}
+
+	if payload.Visibility == "" {
+		payload.Visibility = models.PostVisibilityPublic
+	}
+	if !validAudience(session.ID, payload.Visibility, payload.ChatID) {
+		c.JSON(http.StatusBadRequest, CreateErrors{
+			ChatID: "Invalid field!",
+		})
+		return
+	}
+
+	var poll *models.Poll
+	if payload.Poll != nil {
+		var ok bool
+		poll, ok = newPoll(payload.Poll)
+		if !ok {
+			c.JSON(http.StatusBadRequest, CreateErrors{
+				Poll: "Invalid field!",
+			})
+			return
+		}
+	}
+
+	kind := models.PostKindPost
+	var quoteOf *uint
+	if payload.QuoteOfID != nil {
+		original, ok := findOriginal(*payload.QuoteOfID)
+		if !ok {
+			c.JSON(http.StatusBadRequest, CreateErrors{
+				QuoteOfID: "Invalid field!",
+			})
+			return
+		}
+		kind = models.PostKindQuote
+		quoteOf = &original.ID
+	}
+
+	filtered := contentfilter.Check(session.ID, contentfilter.SourcePost, payload.Content)
+	if filtered.Rejected() {
+		c.JSON(http.StatusBadRequest, CreateErrors{
+			Content: "Content not allowed!",
+		})
+		return
+	}
+
+	attachments, err := uploadAttachments(payload.Attachments)
+	if err != nil {
+		log.Error("Error uploading attachments", err)
+		if err == utils.StatusBadRequest {
+			c.JSON(http.StatusBadRequest, CreateErrors{
+				Attachments: "Invalid field!",
+			})
+			return
+		}
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
 	post := models.Post{
-		Content:  payload.Content,
-		AuthorId: uint(session.ID),
+		Content:     filtered.Content,
+		AuthorId:    uint(session.ID),
+		Kind:        kind,
+		RepostOfId:  quoteOf,
+		Attachments: attachments,
+		Status:      payload.Status,
+		PublishAt:   payload.PublishAt,
+		Visibility:  payload.Visibility,
+		ChatId:      payload.ChatID,
+		Hidden:      filtered.Held(),
 	}
 
-	err = db.DefaultClient.
-		Create(&post).Error
+	pending := []uint{}
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+		if poll != nil {
+			poll.PostId = post.ID
+			if err := tx.Create(poll).Error; err != nil {
+				return err
+			}
+		}
+		if err := saveTags(tx, post.ID, post.Content); err != nil {
+			return err
+		}
+		pending, err = previews.Save(tx, models.MentionSourcePost, post.ID, post.Content)
+		if err != nil {
+			return err
+		}
+		_, err = mentions.Save(tx, models.MentionSourcePost, post.ID, post.Content)
+		return err
+	})
 	if err != nil {
+		removeAttachments(attachments)
 		c.JSON(500, gin.H{
 			"message": "Internal server error",
 		})
 		return
 	}
+	previews.Fetch(pending)
+
+	if post.Hidden {
+		contentfilter.Hold(models.ModerationTargetPost, post.ID, filtered.Rules)
+		c.JSON(202, gin.H{"message": "Held for review"})
+		return
+	}
+	if post.Status == models.PostStatusPublished {
+		AfterPublish(&post)
+	}
 
 	c.JSON(201, gin.H{"message": "created"})
 }
 
+type UpdatePayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+type UpdateErrors struct {
+	Content string `json:"content,omitempty"`
+}
+
 func (h *PostsRouter) update(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
-	payload := &Post{}
+	payload := &UpdatePayload{}
 	err = c.ShouldBind(payload)
 	if err != nil {
 		c.JSON(400, gin.H{
@@ -242,35 +436,170 @@ func (h *PostsRouter) update(c *gin.Context) {
 		return
 	}
 
-	post := models.Post{Content: payload.Content}
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, UpdateErrors{
+			Content: errorsMap["Content"],
+		})
+		return
+	}
 
+	id, _ := strconv.Atoi(c.Param("id"))
+	post := &models.Post{}
 	err = db.DefaultClient.
-		Model(&models.Post{}).
-		Where(models.Post{ID: uint(payload.ID), AuthorId: session.ID}).
-		Updates(&post).Error
+		Where("posts.id = ?", id).
+		First(post).Error
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "Post not found",
+		})
+		return
+	}
+	if post.AuthorId != session.ID {
+		c.JSON(403, gin.H{
+			"message": "Forbidden",
+		})
+		return
+	}
+	if post.Kind == models.PostKindRepost {
+		c.JSON(400, gin.H{
+			"message": "Reposts can not be edited",
+		})
+		return
+	}
+	if post.Content == payload.Content {
+		c.JSON(200, gin.H{"message": "updated"})
+		return
+	}
+
+	filtered := contentfilter.CheckEdit(session.ID, contentfilter.SourcePost, payload.Content)
+	if filtered.Rejected() {
+		c.JSON(http.StatusBadRequest, UpdateErrors{
+			Content: "Content not allowed!",
+		})
+		return
+	}
+	content := filtered.Content
+
+	// Drafts are edited freely, history is only kept once the post is out.
+	published := post.Status == models.PostStatusPublished
+	mentioned := []uint{}
+	pending := []uint{}
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		changes := &models.Post{Content: content}
+		if published {
+			revision := &models.PostRevision{
+				PostId:   post.ID,
+				Content:  post.Content,
+				EditorId: session.ID,
+			}
+			if err := tx.Create(revision).Error; err != nil {
+				return err
+			}
+			now := time.Now()
+			changes.Edited = true
+			changes.EditedAt = &now
+		}
+
+		err := tx.Model(post).Updates(changes).Error
+		if err != nil {
+			return err
+		}
+		if err := saveTags(tx, post.ID, content); err != nil {
+			return err
+		}
+		pending, err = previews.Save(tx, models.MentionSourcePost, post.ID, content)
+		if err != nil {
+			return err
+		}
+		mentioned, err = mentions.Save(tx, models.MentionSourcePost, post.ID, content)
+		return err
+	})
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "Internal server error",
 		})
 		return
 	}
+	previews.Fetch(pending)
+	if published {
+		mentions.Notify(session.ID, models.MentionSourcePost, post.ID, audienceOf(post.ID, mentioned))
+	}
 
 	c.JSON(200, gin.H{"message": "updated"})
 }
 
-func (h *PostsRouter) delete(c *gin.Context) {
+type PostRevision struct {
+	ID         uint      `json:"id"`
+	PostID     uint      `json:"post_id"`
+	Content    string    `json:"content"`
+	EditorID   uint      `json:"editor_id"`
+	CreationAt time.Time `json:"creation_at"`
+}
+
+func (h *PostsRouter) getRevisions(c *gin.Context) {
 	session, err := utils.ValidateSession(c)
 	if err != nil {
-		c.JSON(401, gin.H{
-			"message": "Unauthorized",
-		})
+		utils.Response(c, err)
 		return
 	}
 
 	id, _ := strconv.Atoi(c.Param("id"))
+	post := &models.Post{}
 	err = db.DefaultClient.
-		Where(models.Post{ID: uint(id), AuthorId: session.ID}).
-		Delete(&models.Post{}).Error
+		Unscoped().
+		Where(&models.Post{ID: uint(id)}).
+		First(post).Error
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "Post not found",
+		})
+		return
+	}
+	if post.AuthorId != session.ID && !session.IsModerator() {
+		c.JSON(403, gin.H{
+			"message": "Forbidden",
+		})
+		return
+	}
+
+	cursor := utils.ParseCursor(c)
+	revisions := []*PostRevision{}
+	tx := db.DefaultClient.
+		Model(&models.PostRevision{}).
+		Where(&models.PostRevision{PostId: post.ID})
+	err = cursor.Apply(tx, "post_revisions.id").Find(&revisions).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if cursor.Reversed() {
+		slices.Reverse(revisions)
+	}
+
+	c.JSON(200, revisions)
+}
+
+func (h *PostsRouter) delete(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Where(models.Post{ID: uint(id), AuthorId: session.ID}).
+			Delete(&models.Post{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return deleteReposts(tx, uint(id))
+	})
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "Internal server error",
@@ -280,3 +609,15 @@ func (h *PostsRouter) delete(c *gin.Context) {
 
 	c.JSON(200, gin.H{"message": "deleted"})
 }
+
+// DeletePost removes any post along with its plain reposts, it is meant for
+// moderation where the author is not the one deleting.
+func DeletePost(tx *gorm.DB, postID uint) error {
+	err := tx.
+		Where("id = ?", postID).
+		Delete(&models.Post{}).Error
+	if err != nil {
+		return err
+	}
+	return deleteReposts(tx, postID)
+}
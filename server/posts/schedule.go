@@ -0,0 +1,287 @@
+package posts
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/mentions"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/notifications"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	schedulerLockKey  = "posts-scheduler-lock"
+	schedulerLockTTL  = 25 * time.Second
+	schedulerInterval = 30 * time.Second
+)
+
+// releaseLock deletes a lock only when it is still held by the instance
+// releasing it.
+var releaseLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// publishedScope restricts posts to the ones visible in feeds, posts
+// hidden by moderators are left out.
+func publishedScope(tx *gorm.DB) *gorm.DB {
+	return tx.Where("posts.status = ? AND posts.hidden = false", models.PostStatusPublished)
+}
+
+// applyPublishCursor pages published posts newest first by publish time,
+// the id breaking ties. Cursor ids are resolved to the publish time of
+// their post, so a draft published days later is listed on top.
+func applyPublishCursor(tx *gorm.DB, cursor utils.Cursor) *gorm.DB {
+	const position = "(SELECT anchor.publish_at, anchor.id FROM posts anchor WHERE anchor.id = ?)"
+	if cursor.Before > 0 {
+		tx = tx.Where("(posts.publish_at, posts.id) < "+position, cursor.Before)
+	}
+	if cursor.After > 0 {
+		tx = tx.Where("(posts.publish_at, posts.id) > "+position, cursor.After).
+			Order("posts.publish_at ASC, posts.id ASC")
+	} else {
+		tx = tx.Order("posts.publish_at DESC, posts.id DESC")
+	}
+	return tx.Limit(cursor.Limit)
+}
+
+// StartScheduler publishes the scheduled posts once they are due. Every API
+// instance runs it, the Redis lock lets a single one work on each tick.
+func StartScheduler() {
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			publishDue()
+		}
+	}()
+}
+
+// withLock runs fn only on the instance that acquires the lock, so periodic
+// jobs run once per tick across every API instance.
+func withLock(key string, ttl time.Duration, fn func()) {
+	ctx := context.Background()
+	token, err := utils.GenerateRandomString(32)
+	if err != nil {
+		log.Error("Error generating lock token", err)
+		return
+	}
+
+	acquired, err := db.DefaultCache.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		log.Error("Error acquiring lock", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+	defer func() {
+		err := releaseLock.Run(ctx, db.DefaultCache, []string{key}, token).Err()
+		if err != nil {
+			log.Error("Error releasing lock", err)
+		}
+	}()
+
+	fn()
+}
+
+func publishDue() {
+	withLock(schedulerLockKey, schedulerLockTTL, func() {
+		// The update only matches posts still scheduled, so a post is
+		// returned to exactly one caller even if the lock expired mid tick.
+		due := []models.Post{}
+		err := db.DefaultClient.
+			Model(&due).
+			Clauses(clause.Returning{}).
+			Where("status = ? AND publish_at <= ?", models.PostStatusScheduled, time.Now()).
+			Update("status", models.PostStatusPublished).Error
+		if err != nil {
+			log.Error("Error publishing scheduled posts", err)
+			return
+		}
+
+		// Held posts reach feeds only if a moderator restores them.
+		for i := range due {
+			if !due[i].Hidden {
+				AfterPublish(&due[i])
+			}
+		}
+	})
+}
+
+// AfterPublish fans a post that just became visible out to the home feeds
+// and notifies the quoted author and the users mentioned in it. Held posts
+// go through it once a moderator restores them.
+func AfterPublish(post *models.Post) {
+	pushToHomeFeeds(post)
+	if post.Kind == models.PostKindQuote && post.RepostOfId != nil {
+		original, ok := findOriginal(*post.RepostOfId)
+		if ok {
+			notifications.Notify(original.AuthorId, post.AuthorId, models.NotificationQuote, models.MentionSourcePost, post.ID)
+		}
+	}
+
+	mentioned, err := mentions.Mentioned(models.MentionSourcePost, post.ID)
+	if err != nil {
+		log.Error("Error loading post mentions", err)
+		return
+	}
+	mentions.Notify(post.AuthorId, models.MentionSourcePost, post.ID, audienceOf(post.ID, mentioned))
+}
+
+func (h *PostsRouter) drafts(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	tx := feedQuery(session.ID).
+		Where("posts.author_id = ? AND posts.status <> ?", session.ID, models.PostStatusPublished)
+	if status := c.Query("status"); status != "" {
+		tx = tx.Where("posts.status = ?", status)
+	}
+
+	cursor := utils.ParseCursor(c)
+	posts := []*Post{}
+	err = cursor.Apply(tx, "posts.id").Find(&posts).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if cursor.Reversed() {
+		slices.Reverse(posts)
+	}
+	hydratePosts(session.ID, posts)
+
+	c.JSON(200, posts)
+}
+
+func (h *PostsRouter) publish(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	published := []models.Post{}
+	result := db.DefaultClient.
+		Model(&published).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND author_id = ? AND status <> ?", id, session.ID, models.PostStatusPublished).
+		Updates(map[string]interface{}{
+			"status":     models.PostStatusPublished,
+			"publish_at": time.Now(),
+		})
+	if result.Error != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(404, gin.H{
+			"message": "Draft not found",
+		})
+		return
+	}
+	if !published[0].Hidden {
+		AfterPublish(&published[0])
+	}
+
+	c.JSON(200, gin.H{"message": "published"})
+}
+
+type SchedulePayload struct {
+	PublishAt *time.Time `json:"publish_at" validate:"required"`
+}
+
+type ScheduleErrors struct {
+	PublishAt string `json:"publish_at,omitempty"`
+}
+
+func (h *PostsRouter) schedule(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := &SchedulePayload{}
+	err = c.ShouldBind(payload)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "Invalid payload",
+		})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, ScheduleErrors{
+			PublishAt: errorsMap["PublishAt"],
+		})
+		return
+	}
+	if !payload.PublishAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, ScheduleErrors{
+			PublishAt: "Invalid field!",
+		})
+		return
+	}
+
+	h.setStatus(c, session, models.PostStatusScheduled, payload.PublishAt)
+}
+
+func (h *PostsRouter) unschedule(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	h.setStatus(c, session, models.PostStatusDraft, nil)
+}
+
+// setStatus moves a post of the session user that is not published yet
+// between draft and scheduled.
+func (h *PostsRouter) setStatus(c *gin.Context, session *utils.User, status string, publishAt *time.Time) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	result := db.DefaultClient.
+		Model(&models.Post{}).
+		Where("id = ? AND author_id = ? AND status <> ?", id, session.ID, models.PostStatusPublished).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"publish_at": publishAt,
+		})
+	if result.Error != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(404, gin.H{
+			"message": "Draft not found",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "updated"})
+}
@@ -0,0 +1,52 @@
+package posts
+
+import (
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// saveTags syncs the tags of a post with the #hashtags in its content, tags
+// kept across edits keep their row.
+func saveTags(tx *gorm.DB, postID uint, content string) error {
+	names := utils.ParseHashtags(content)
+
+	stale := tx.Where("post_tags.post_id = ?", postID)
+	if len(names) > 0 {
+		stale = stale.Where("post_tags.tag_id NOT IN (SELECT tags.id FROM tags WHERE tags.name IN ?)", names)
+	}
+	if err := stale.Delete(&models.PostTag{}).Error; err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	tags := []models.Tag{}
+	for _, name := range names {
+		tags = append(tags, models.Tag{Name: name})
+	}
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&tags).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec(
+		"INSERT INTO post_tags (post_id, tag_id, creation_at) "+
+			"SELECT ?, tags.id, now() FROM tags WHERE tags.name IN ? "+
+			"ON CONFLICT DO NOTHING",
+		postID, names,
+	).Error
+}
+
+// TagScope restricts posts to the ones tagged with name.
+func TagScope(name string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(
+			"posts.id IN (SELECT post_tags.post_id FROM post_tags JOIN tags ON tags.id = post_tags.tag_id WHERE tags.name = ?)",
+			utils.NormalizeTag(name),
+		)
+	}
+}
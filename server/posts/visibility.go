@@ -0,0 +1,146 @@
+package posts
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
+)
+
+// visibleScope restricts posts to the ones the viewer is in the audience
+// of, the anonymous viewer 0 only sees public posts.
+func visibleScope(viewerID uint) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(
+			"posts.visibility = 'public' OR posts.author_id = @viewer OR "+
+				"(posts.visibility = 'followers' AND posts.author_id IN (SELECT followee_id FROM follows WHERE follower_id = @viewer)) OR "+
+				"(posts.visibility = 'chat' AND posts.chat_id IN (SELECT chat_id FROM chat_users WHERE user_id = @viewer AND deleted_at IS NULL))",
+			sql.Named("viewer", viewerID),
+		)
+	}
+}
+
+// findVisible loads a published post the viewer is allowed to see.
+func findVisible(viewerID uint, postID uint) (*models.Post, bool) {
+	post := &models.Post{}
+	err := db.DefaultClient.
+		Scopes(publishedScope, visibleScope(viewerID)).
+		Where("posts.id = ?", postID).
+		First(post).Error
+	return post, err == nil
+}
+
+// audienceOf keeps the users allowed to see the post, so mentions in posts
+// they can not read do not reach them.
+func audienceOf(postID uint, userIDs []uint) []uint {
+	allowed := []uint{}
+	for _, userID := range userIDs {
+		if _, ok := findVisible(userID, postID); ok {
+			allowed = append(allowed, userID)
+		}
+	}
+	return allowed
+}
+
+// viewerOf returns the session user or 0 for anonymous readers, it
+// answers the request when a token was sent but is not valid.
+func viewerOf(c *gin.Context) (uint, bool) {
+	session, err := utils.OptionalSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return 0, false
+	}
+	if session == nil {
+		return 0, true
+	}
+	return session.ID, true
+}
+
+// validAudience checks that a chat audience is a chat the author belongs
+// to, other visibilities take no chat.
+func validAudience(authorID uint, visibility string, chatID *uint) bool {
+	if visibility != models.PostVisibilityChat {
+		return chatID == nil
+	}
+	if chatID == nil {
+		return false
+	}
+
+	count := int64(0)
+	db.DefaultClient.
+		Model(&models.ChatUser{}).
+		Where(&models.ChatUser{ChatId: *chatID, UserId: authorID}).
+		Count(&count)
+	return count > 0
+}
+
+type AudiencePayload struct {
+	Visibility string `json:"visibility" validate:"required,oneof=public followers private chat"`
+	ChatID     *uint  `json:"chat_id"`
+}
+
+type AudienceErrors struct {
+	Visibility string `json:"visibility,omitempty"`
+	ChatID     string `json:"chat_id,omitempty"`
+}
+
+func (h *PostsRouter) setAudience(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload := &AudiencePayload{}
+	err = c.ShouldBind(payload)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "Invalid payload",
+		})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, AudienceErrors{
+			Visibility: errorsMap["Visibility"],
+		})
+		return
+	}
+	if !validAudience(session.ID, payload.Visibility, payload.ChatID) {
+		c.JSON(http.StatusBadRequest, AudienceErrors{
+			ChatID: "Invalid field!",
+		})
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	result := db.DefaultClient.
+		Model(&models.Post{}).
+		Where("id = ? AND author_id = ?", id, session.ID).
+		Updates(map[string]interface{}{
+			"visibility": payload.Visibility,
+			"chat_id":    payload.ChatID,
+		})
+	if result.Error != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(404, gin.H{
+			"message": "Post not found",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "updated"})
+}
@@ -0,0 +1,270 @@
+package previews
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	fetchTimeout   = 10 * time.Second
+	dialTimeout    = 5 * time.Second
+	maxBodySize    = 512 * 1024
+	maxHeaderBytes = 64 * 1024
+	maxRedirects   = 5
+	userAgent      = "SpecialistTalkBot/1.0 (+link preview)"
+)
+
+var (
+	errBlockedAddress   = errors.New("address not allowed")
+	errInvalidURL       = errors.New("invalid url")
+	errTooManyRedirects = errors.New("too many redirects")
+	errNotHTML          = errors.New("not an html page")
+)
+
+// blockedNetworks are ranges not covered by the net.IP helpers that must
+// not be reachable from the fetcher either.
+var blockedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// publicIP tells whether an address is routable on the internet, loopback,
+// private, link local and reserved addresses are not.
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Card is the metadata read from the OpenGraph and Twitter card tags of a
+// page, the title and description tags are the fallback.
+type Card struct {
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// Fetcher downloads pages to build their cards. The address is checked
+// when connecting, after DNS resolution, so a host resolving to a private
+// address is refused on every redirect as well.
+type Fetcher struct {
+	Client *http.Client
+
+	// AllowPrivate lifts the address checks, only meant for tests against
+	// local servers.
+	AllowPrivate bool
+}
+
+func NewFetcher() *Fetcher {
+	fetcher := &Fetcher{}
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+		Control: fetcher.control,
+	}
+	fetcher.Client = &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			DialContext:            dialer.DialContext,
+			TLSHandshakeTimeout:    dialTimeout,
+			ResponseHeaderTimeout:  dialTimeout,
+			MaxResponseHeaderBytes: maxHeaderBytes,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errTooManyRedirects
+			}
+			return fetcher.checkURL(req.URL)
+		},
+	}
+	return fetcher
+}
+
+func (f *Fetcher) control(network, address string, _ syscall.RawConn) error {
+	if f.AllowPrivate {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) || (port != "80" && port != "443") {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+func (f *Fetcher) checkURL(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return errInvalidURL
+	}
+	if target.User != nil || target.Hostname() == "" {
+		return errInvalidURL
+	}
+	if f.AllowPrivate {
+		return nil
+	}
+	if ip := net.ParseIP(target.Hostname()); ip != nil && !publicIP(ip) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// Fetch downloads at most maxBodySize bytes of the page and reads its card.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Card, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errInvalidURL
+	}
+	if err := f.checkURL(target); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	res, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected status " + res.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, errNotHTML
+	}
+
+	card := parseCard(io.LimitReader(res.Body, maxBodySize), res.Request.URL)
+	if card.Title == "" {
+		return nil, errNotHTML
+	}
+	return card, nil
+}
+
+// parseCard reads the meta tags of the head of a page, image URLs are
+// resolved against the page URL.
+func parseCard(body io.Reader, base *url.URL) *Card {
+	meta := map[string]string{}
+	title := ""
+	inTitle := false
+
+	tokenizer := html.NewTokenizer(body)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return buildCard(meta, title, base)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "body":
+				return buildCard(meta, title, base)
+			case "title":
+				inTitle = title == ""
+			case "meta":
+				key, content := "", ""
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "property", "name":
+						key = strings.ToLower(strings.TrimSpace(attr.Val))
+					case "content":
+						content = strings.TrimSpace(attr.Val)
+					}
+				}
+				if _, ok := meta[key]; key != "" && content != "" && !ok {
+					meta[key] = content
+				}
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return buildCard(meta, title, base)
+			}
+		}
+	}
+}
+
+func buildCard(meta map[string]string, title string, base *url.URL) *Card {
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if value := meta[key]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	card := &Card{
+		Title:       truncate(first("og:title", "twitter:title"), 300),
+		Description: truncate(first("og:description", "twitter:description", "description"), 1000),
+		SiteName:    truncate(first("og:site_name"), 200),
+	}
+	if card.Title == "" {
+		card.Title = truncate(strings.Join(strings.Fields(title), " "), 300)
+	}
+	if card.SiteName == "" {
+		card.SiteName = base.Hostname()
+	}
+	if image := first("og:image", "og:image:url", "twitter:image", "twitter:image:src"); image != "" {
+		resolved, err := base.Parse(image)
+		if err == nil && (resolved.Scheme == "http" || resolved.Scheme == "https") && len(resolved.String()) <= 2048 {
+			card.ImageURL = resolved.String()
+		}
+	}
+	return card
+}
+
+// truncate cuts a string to limit runes without splitting a character,
+// invalid UTF-8 sequences are dropped.
+func truncate(value string, limit int) string {
+	value = strings.ToValidUTF8(value, "")
+	if utf8.RuneCountInString(value) <= limit {
+		return value
+	}
+	return string([]rune(value)[:limit])
+}
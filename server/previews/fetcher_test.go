@@ -0,0 +1,235 @@
+package previews
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestParseCard(t *testing.T) {
+	base := "https://example.com/articles/1"
+	cases := []struct {
+		name string
+		page string
+		want Card
+	}{
+		{
+			name: "opengraph",
+			page: `<html><head>
+				<title>Page title</title>
+				<meta property="og:title" content="OG title">
+				<meta property="og:description" content="OG description">
+				<meta property="og:site_name" content="Example">
+				<meta property="og:image" content="https://cdn.example.com/a.png">
+				<meta name="twitter:title" content="Twitter title">
+			</head><body></body></html>`,
+			want: Card{
+				Title:       "OG title",
+				Description: "OG description",
+				ImageURL:    "https://cdn.example.com/a.png",
+				SiteName:    "Example",
+			},
+		},
+		{
+			name: "twitter",
+			page: `<html><head>
+				<title>Page title</title>
+				<meta name="twitter:title" content="Twitter title">
+				<meta name="twitter:description" content="Twitter description">
+				<meta name="twitter:image" content="https://cdn.example.com/b.png">
+			</head></html>`,
+			want: Card{
+				Title:       "Twitter title",
+				Description: "Twitter description",
+				ImageURL:    "https://cdn.example.com/b.png",
+				SiteName:    "example.com",
+			},
+		},
+		{
+			name: "title fallback",
+			page: `<html><head>
+				<title>
+					Page   title
+				</title>
+				<meta name="description" content="Plain description">
+			</head></html>`,
+			want: Card{
+				Title:       "Page title",
+				Description: "Plain description",
+				SiteName:    "example.com",
+			},
+		},
+		{
+			name: "relative image",
+			page: `<html><head>
+				<meta property="og:title" content="OG title">
+				<meta property="og:image" content="../images/c.png">
+			</head></html>`,
+			want: Card{
+				Title:    "OG title",
+				ImageURL: "https://example.com/images/c.png",
+				SiteName: "example.com",
+			},
+		},
+		{
+			name: "image with another scheme",
+			page: `<html><head>
+				<meta property="og:title" content="OG title">
+				<meta property="og:image" content="javascript:alert(1)">
+			</head></html>`,
+			want: Card{
+				Title:    "OG title",
+				SiteName: "example.com",
+			},
+		},
+		{
+			name: "tags of the body",
+			page: `<html><head><title>Page title</title></head>
+				<body><meta property="og:title" content="Body title"></body></html>`,
+			want: Card{
+				Title:    "Page title",
+				SiteName: "example.com",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			card := parseCard(strings.NewReader(tc.page), mustParseURL(t, base))
+			if *card != tc.want {
+				t.Errorf("got %+v, want %+v", *card, tc.want)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><meta property="og:title" content="Served"></head></html>`))
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher()
+	fetcher.AllowPrivate = true
+	card, err := fetcher.Fetch(context.Background(), server.URL+"/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.Title != "Served" || card.SiteName != "127.0.0.1" {
+		t.Errorf("unexpected card %+v", *card)
+	}
+}
+
+func TestFetchBlockedAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the server must not be reached")
+	}))
+	defer server.Close()
+
+	_, err := NewFetcher().Fetch(context.Background(), server.URL)
+	if !errors.Is(err, errBlockedAddress) {
+		t.Errorf("got %v, want %v", err, errBlockedAddress)
+	}
+}
+
+func TestFetchRedirectToBlockedAddress(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the internal server must not be reached")
+	}))
+	defer internal.Close()
+
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL+"/admin", http.StatusFound)
+	}))
+	defer public.Close()
+
+	// The public host resolves to the first server, the redirect it answers
+	// with must still go through the address checks.
+	fetcher := NewFetcher()
+	fetcher.Client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, public.Listener.Addr().String())
+		},
+	}
+	_, err := fetcher.Fetch(context.Background(), "http://example.com/")
+	if !errors.Is(err, errBlockedAddress) {
+		t.Errorf("got %v, want %v", err, errBlockedAddress)
+	}
+}
+
+func TestFetcherControl(t *testing.T) {
+	cases := []struct {
+		address string
+		blocked bool
+	}{
+		{"93.184.216.34:443", false},
+		{"93.184.216.34:80", false},
+		{"93.184.216.34:22", true},
+		{"127.0.0.1:80", true},
+		{"10.0.0.1:443", true},
+		{"169.254.169.254:80", true},
+		{"100.64.0.1:80", true},
+		{"[::1]:443", true},
+		{"[64:ff9b::a00:1]:443", true},
+	}
+
+	fetcher := NewFetcher()
+	for _, tc := range cases {
+		err := fetcher.control("tcp", tc.address, nil)
+		if blocked := errors.Is(err, errBlockedAddress); blocked != tc.blocked {
+			t.Errorf("%s: got %v, want blocked %v", tc.address, err, tc.blocked)
+		}
+	}
+}
+
+func TestFetchBodySizeCap(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Head title</title><!--`))
+		w.Write([]byte(strings.Repeat("x", maxBodySize)))
+		w.Write([]byte(`--><meta property="og:title" content="Past the cap"></head></html>`))
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher()
+	fetcher.AllowPrivate = true
+	card, err := fetcher.Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.Title != "Head title" {
+		t.Errorf("got title %q, the tags past %d bytes must not be read", card.Title, maxBodySize)
+	}
+}
+
+func TestFetchNotHTML(t *testing.T) {
+	for _, contentType := range []string{"application/json", "image/png", ""} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Write([]byte(`<html><head><title>Disguised</title></head></html>`))
+		}))
+
+		fetcher := NewFetcher()
+		fetcher.AllowPrivate = true
+		_, err := fetcher.Fetch(context.Background(), server.URL)
+		if !errors.Is(err, errNotHTML) {
+			t.Errorf("%q: got %v, want %v", contentType, err, errNotHTML)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,225 @@
+package previews
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/logger"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var log = logger.SetupLogger()
+
+const (
+	// maxLinks is how many URLs of a piece of content get a preview.
+	maxLinks = 5
+	maxURL   = 2048
+
+	// Ready previews are refreshed after a week, failed ones are retried
+	// after an hour.
+	refreshAfter = 7 * 24 * time.Hour
+	retryAfter   = time.Hour
+
+	fetchLockTTL = time.Minute
+)
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+// DefaultFetcher downloads the pages of every preview.
+var DefaultFetcher = NewFetcher()
+
+type Preview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// ParseURLs returns the distinct URLs of content in order, punctuation
+// right after a URL is left out.
+func ParseURLs(content string) []string {
+	urls := []string{}
+	seen := map[string]bool{}
+	for _, match := range urlPattern.FindAllString(content, -1) {
+		match = strings.TrimRight(match, ".,;:!?)]}'")
+		if len(match) > maxURL || seen[match] {
+			continue
+		}
+		seen[match] = true
+		urls = append(urls, match)
+		if len(urls) == maxLinks {
+			break
+		}
+	}
+	return urls
+}
+
+// stale tells whether a preview has to be fetched again.
+func stale(preview *models.LinkPreview) bool {
+	if preview.FetchedAt == nil {
+		return true
+	}
+	age := time.Since(*preview.FetchedAt)
+	if preview.Status == models.LinkPreviewFailed {
+		return age > retryAfter
+	}
+	return age > refreshAfter
+}
+
+// Save replaces the links of a source with the URLs in content and returns
+// the previews that have to be fetched once the transaction commits.
+func Save(tx *gorm.DB, sourceType string, sourceID uint, content string) ([]uint, error) {
+	err := tx.Where(&models.Link{SourceType: sourceType, SourceId: sourceID}).
+		Delete(&models.Link{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	urls := ParseURLs(content)
+	if len(urls) == 0 {
+		return []uint{}, nil
+	}
+
+	records := []models.LinkPreview{}
+	for _, url := range urls {
+		records = append(records, models.LinkPreview{URL: url})
+	}
+	err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	previews := []*models.LinkPreview{}
+	err = tx.Where("url IN ?", urls).Find(&previews).Error
+	if err != nil {
+		return nil, err
+	}
+	byURL := map[string]*models.LinkPreview{}
+	for _, preview := range previews {
+		byURL[preview.URL] = preview
+	}
+
+	links := []models.Link{}
+	pending := []uint{}
+	for position, url := range urls {
+		preview, ok := byURL[url]
+		if !ok {
+			continue
+		}
+		links = append(links, models.Link{
+			SourceType:    sourceType,
+			SourceId:      sourceID,
+			LinkPreviewId: preview.ID,
+			Position:      position,
+		})
+		if stale(preview) {
+			pending = append(pending, preview.ID)
+		}
+	}
+
+	return pending, tx.Create(&links).Error
+}
+
+// Fetch builds the given previews in the background, a Redis lock keeps
+// two instances from fetching the same page at once.
+func Fetch(previewIDs []uint) {
+	if len(previewIDs) == 0 {
+		return
+	}
+	go func() {
+		for _, previewID := range previewIDs {
+			fetch(previewID)
+		}
+	}()
+}
+
+func fetch(previewID uint) {
+	ctx := context.Background()
+	lockKey := "link-preview-fetch-" + strconv.Itoa(int(previewID))
+	acquired, err := db.DefaultCache.SetNX(ctx, lockKey, 1, fetchLockTTL).Result()
+	if err != nil || !acquired {
+		return
+	}
+	defer db.DefaultCache.Del(ctx, lockKey)
+
+	preview := &models.LinkPreview{}
+	err = db.DefaultClient.Where(&models.LinkPreview{ID: previewID}).First(preview).Error
+	if err != nil || !stale(preview) {
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"fetched_at": now}
+	card, err := DefaultFetcher.Fetch(ctx, preview.URL)
+	if err != nil {
+		log.Info("Error fetching link preview of "+preview.URL, err)
+		// A card fetched before is kept until the page is back.
+		if preview.Status != models.LinkPreviewReady {
+			updates["status"] = models.LinkPreviewFailed
+		}
+	} else {
+		updates["status"] = models.LinkPreviewReady
+		updates["title"] = card.Title
+		updates["description"] = card.Description
+		updates["image_url"] = card.ImageURL
+		updates["site_name"] = card.SiteName
+	}
+
+	err = db.DefaultClient.
+		Model(&models.LinkPreview{}).
+		Where("id = ?", previewID).
+		Updates(updates).Error
+	if err != nil {
+		log.Error("Error saving link preview", err)
+	}
+}
+
+// Find loads the ready previews of the given sources grouped by source id,
+// stale previews are refreshed in the background.
+func Find(sourceType string, sourceIDs []uint) map[uint][]Preview {
+	result := map[uint][]Preview{}
+	if len(sourceIDs) == 0 {
+		return result
+	}
+
+	links := []models.Link{}
+	err := db.DefaultClient.
+		Preload("LinkPreview").
+		Where("source_type = ? AND source_id IN ?", sourceType, sourceIDs).
+		Order("position").
+		Find(&links).Error
+	if err != nil {
+		log.Error("Error loading link previews", err)
+		return result
+	}
+
+	refresh := []uint{}
+	seen := map[uint]bool{}
+	for _, link := range links {
+		preview := link.LinkPreview
+		if stale(&preview) && !seen[preview.ID] {
+			seen[preview.ID] = true
+			refresh = append(refresh, preview.ID)
+		}
+		if preview.Status != models.LinkPreviewReady {
+			continue
+		}
+		result[link.SourceId] = append(result[link.SourceId], Preview{
+			URL:         preview.URL,
+			Title:       preview.Title,
+			Description: preview.Description,
+			ImageURL:    preview.ImageURL,
+			SiteName:    preview.SiteName,
+		})
+	}
+	Fetch(refresh)
+
+	return result
+}
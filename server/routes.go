@@ -2,9 +2,13 @@ package server
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/assets"
 	"github.com/juliotorresmoreno/specialist-talk-api/server/auth"
 	"github.com/juliotorresmoreno/specialist-talk-api/server/chats"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/moderation"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/notifications"
 	"github.com/juliotorresmoreno/specialist-talk-api/server/posts"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/tags"
 	"github.com/juliotorresmoreno/specialist-talk-api/server/users"
 )
 
@@ -13,4 +17,8 @@ func SetupAPIRoutes(r *gin.RouterGroup) {
 	users.SetupAPIRoutes(r.Group("/users"))
 	posts.SetupApiRoutes(r.Group("/posts"))
 	chats.SetupAPIRoutes(r.Group("/chats"))
+	assets.SetupAPIRoutes(r.Group("/assets"))
+	tags.SetupAPIRoutes(r.Group("/tags"))
+	notifications.SetupAPIRoutes(r.Group("/notifications"))
+	moderation.SetupAPIRoutes(r.Group("/moderation"))
 }
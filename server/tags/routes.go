@@ -0,0 +1,244 @@
+package tags
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/logger"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/posts"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm/clause"
+)
+
+var log = logger.SetupLogger()
+
+// trendingWindows are the rolling windows trending tags can be computed
+// over, results are cached for trendingTTL.
+var trendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+const trendingTTL = 5 * time.Minute
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+type TagsRouter struct{}
+
+func SetupAPIRoutes(g *gin.RouterGroup) {
+	h := &TagsRouter{}
+
+	g.GET("", h.find)
+	g.GET("/trending", h.trending)
+	g.GET("/following", h.following)
+	g.GET("/:tag/posts", h.posts)
+	g.POST("/:tag/follow", h.follow)
+	g.DELETE("/:tag/follow", h.unfollow)
+}
+
+type Tag struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Posts int64  `json:"posts"`
+}
+
+func (h *TagsRouter) find(c *gin.Context) {
+	_, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	// Only public posts are counted, a tag used in nothing else is not
+	// suggested at all so private posts do not give it away.
+	tags := []*Tag{}
+	err = db.DefaultClient.
+		Model(&models.Tag{}).
+		Select("tags.id, tags.name, count(*) AS posts").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins(
+			"JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL AND posts.status = ? AND posts.visibility = ? AND posts.hidden = false",
+			models.PostStatusPublished, models.PostVisibilityPublic,
+		).
+		Where("tags.name LIKE ?", likeEscaper.Replace(utils.NormalizeTag(c.Query("q")))+"%").
+		Group("tags.id").
+		Order("count(*) DESC, tags.name").
+		Limit(10).
+		Scan(&tags).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, tags)
+}
+
+func (h *TagsRouter) trending(c *gin.Context) {
+	_, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	window := c.DefaultQuery("window", "24h")
+	duration, ok := trendingWindows[window]
+	if !ok {
+		c.JSON(400, gin.H{
+			"message": "Invalid window",
+		})
+		return
+	}
+
+	key := "tags-trending-" + window
+	if cached, err := db.DefaultCache.Get(context.Background(), key).Bytes(); err == nil {
+		c.Data(200, "application/json; charset=utf-8", cached)
+		return
+	}
+
+	tags := []*Tag{}
+	err = db.DefaultClient.
+		Model(&models.PostTag{}).
+		Select("tags.id, tags.name, count(*) AS posts").
+		Joins("JOIN tags ON tags.id = post_tags.tag_id").
+		Joins(
+			"JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL AND posts.status = ? AND posts.visibility = ? AND posts.hidden = false",
+			models.PostStatusPublished, models.PostVisibilityPublic,
+		).
+		// Posts count from when they were published, editing an old post or
+		// publishing a draft written long ago does not make it trend again.
+		Where("posts.publish_at > ?", time.Now().Add(-duration)).
+		Group("tags.id").
+		Order("count(*) DESC, tags.name").
+		Limit(20).
+		Scan(&tags).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	data, _ := json.Marshal(tags)
+	err = db.DefaultCache.Set(context.Background(), key, data, trendingTTL).Err()
+	if err != nil {
+		log.Error("Error caching trending tags", err)
+	}
+
+	c.JSON(200, tags)
+}
+
+func (h *TagsRouter) following(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	tags := []*Tag{}
+	err = db.DefaultClient.
+		Model(&models.Tag{}).
+		Select("tags.id, tags.name").
+		Joins("JOIN tag_follows ON tag_follows.tag_id = tags.id").
+		Where("tag_follows.user_id = ?", session.ID).
+		Order("tags.name").
+		Scan(&tags).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, tags)
+}
+
+func (h *TagsRouter) posts(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	result, err := posts.FindPosts(session.ID, utils.ParseCursor(c), posts.TagScope(c.Param("tag")))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+func (h *TagsRouter) follow(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	name := utils.NormalizeTag(c.Param("tag"))
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		c.JSON(400, gin.H{
+			"message": "Invalid tag",
+		})
+		return
+	}
+
+	tag := &models.Tag{Name: name}
+	err = db.DefaultClient.
+		Where(tag).
+		FirstOrCreate(tag).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	err = db.DefaultClient.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.TagFollow{UserId: session.ID, TagId: tag.ID}).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	posts.InvalidateHomeFeed(session.ID)
+
+	c.JSON(201, gin.H{"message": "Tag followed"})
+}
+
+func (h *TagsRouter) unfollow(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	err = db.DefaultClient.
+		Where(
+			"user_id = ? AND tag_id IN (SELECT id FROM tags WHERE name = ?)",
+			session.ID, utils.NormalizeTag(c.Param("tag")),
+		).
+		Delete(&models.TagFollow{}).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "Internal server error",
+		})
+		return
+	}
+	posts.InvalidateHomeFeed(session.ID)
+
+	c.JSON(200, gin.H{"message": "Tag unfollowed"})
+}
@@ -0,0 +1,149 @@
+package users
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+)
+
+const (
+	defaultAnalyticsDays = 30
+	maxAnalyticsDays     = 365
+	analyticsTopPosts    = 20
+)
+
+type AnalyticsTotals struct {
+	Views           int64 `json:"views"`
+	Reactions       int64 `json:"reactions"`
+	Comments        int64 `json:"comments"`
+	FollowersGained int64 `json:"followers_gained"`
+}
+
+type AnalyticsDay struct {
+	Day             string `json:"day"`
+	Views           int64  `json:"views"`
+	UniqueViewers   int64  `json:"unique_viewers"`
+	Reactions       int64  `json:"reactions"`
+	Comments        int64  `json:"comments"`
+	FollowersGained int64  `json:"followers_gained"`
+}
+
+type AnalyticsPost struct {
+	ID            uint      `json:"id"`
+	Content       string    `json:"content"`
+	Views         int64     `json:"views"`
+	UniqueViewers int64     `json:"unique_viewers"`
+	Reactions     int64     `json:"reactions"`
+	Comments      int64     `json:"comments"`
+	CreationAt    time.Time `json:"creation_at"`
+}
+
+type Analytics struct {
+	From   string           `json:"from"`
+	To     string           `json:"to"`
+	Totals AnalyticsTotals  `json:"totals"`
+	Daily  []AnalyticsDay   `json:"daily"`
+	Posts  []*AnalyticsPost `json:"posts"`
+}
+
+// dailyAnalyticsQuery aggregates every day of the range, views come from
+// the flushed counters and the rest is counted from the source tables.
+// Interactions of the author on its own posts are left out.
+const dailyAnalyticsQuery = `
+SELECT to_char(days.day, 'YYYY-MM-DD') AS day,
+	COALESCE(author_stats.views, 0) AS views,
+	COALESCE(author_stats.unique_viewers, 0) AS unique_viewers,
+	(SELECT count(*) FROM reactions JOIN posts ON posts.id = reactions.target_id
+		WHERE reactions.target_type = @post AND posts.author_id = @user AND posts.deleted_at IS NULL
+		AND reactions.author_id <> @user
+		AND reactions.creation_at >= days.day AND reactions.creation_at < days.day + interval '1 day') AS reactions,
+	(SELECT count(*) FROM comments JOIN posts ON posts.id = comments.post_id
+		WHERE posts.author_id = @user AND posts.deleted_at IS NULL
+		AND comments.author_id <> @user AND comments.deleted_at IS NULL
+		AND comments.creation_at >= days.day AND comments.creation_at < days.day + interval '1 day') AS comments,
+	(SELECT count(*) FROM follows
+		WHERE follows.followee_id = @user
+		AND follows.creation_at >= days.day AND follows.creation_at < days.day + interval '1 day') AS followers_gained
+FROM generate_series(CAST(@from AS date), CAST(@to AS date), interval '1 day') AS days(day)
+LEFT JOIN author_stats ON author_stats.user_id = @user AND author_stats.day = days.day
+ORDER BY days.day`
+
+// analytics reports the reach of the user's posts, day by day over the
+// last ?days days along with the most viewed posts.
+func (h *UsersRouter) analytics(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	days := defaultAnalyticsDays
+	if value := c.Query("days"); value != "" {
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 1 || days > maxAnalyticsDays {
+			utils.Response(c, utils.StatusBadRequest)
+			return
+		}
+	}
+	to := time.Now()
+	from := to.AddDate(0, 0, 1-days)
+
+	result := &Analytics{
+		From:  from.Format(time.DateOnly),
+		To:    to.Format(time.DateOnly),
+		Daily: []AnalyticsDay{},
+		Posts: []*AnalyticsPost{},
+	}
+	err = db.DefaultClient.
+		Raw(
+			dailyAnalyticsQuery,
+			sql.Named("user", session.ID),
+			sql.Named("post", models.ReactionTargetPost),
+			sql.Named("from", result.From),
+			sql.Named("to", result.To),
+		).
+		Scan(&result.Daily).Error
+	if err != nil {
+		log.Error("Error getting daily analytics", err)
+		utils.Response(c, utils.StatusInternalServerError)
+		return
+	}
+	for _, day := range result.Daily {
+		result.Totals.Views += day.Views
+		result.Totals.Reactions += day.Reactions
+		result.Totals.Comments += day.Comments
+		result.Totals.FollowersGained += day.FollowersGained
+	}
+
+	err = db.DefaultClient.
+		Model(&models.Post{}).
+		Select(
+			"posts.id, posts.content, posts.creation_at, "+
+				"COALESCE(post_stats.views, 0) AS views, "+
+				"COALESCE(post_stats.unique_viewers, 0) AS unique_viewers, "+
+				"(SELECT count(*) FROM reactions WHERE reactions.target_type = @post AND reactions.target_id = posts.id "+
+				"AND reactions.author_id <> @user) AS reactions, "+
+				"(SELECT count(*) FROM comments WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL "+
+				"AND comments.author_id <> @user) AS comments",
+			sql.Named("user", session.ID),
+			sql.Named("post", models.ReactionTargetPost),
+		).
+		Joins("LEFT JOIN post_stats ON post_stats.post_id = posts.id").
+		Where("posts.author_id = ? AND posts.status = ? AND posts.kind <> ?",
+			session.ID, models.PostStatusPublished, models.PostKindRepost).
+		Order("views DESC, posts.id DESC").
+		Limit(analyticsTopPosts).
+		Find(&result.Posts).Error
+	if err != nil {
+		log.Error("Error getting post analytics", err)
+		utils.Response(c, utils.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(200, result)
+}
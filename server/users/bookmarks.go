@@ -0,0 +1,187 @@
+package users
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/posts"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm"
+)
+
+type BookmarkCollection struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name" validate:"required,max=100"`
+	Bookmarks  int64     `json:"bookmarks" gorm:"->"`
+	CreationAt time.Time `json:"creation_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type BookmarkCollectionErrors struct {
+	Name string `json:"name,omitempty"`
+}
+
+func (h *UsersRouter) bookmarks(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	var collectionID *uint
+	if value := c.Query("collection_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			utils.Response(c, utils.StatusBadRequest)
+			return
+		}
+		collectionID = new(uint)
+		*collectionID = uint(id)
+	}
+
+	result, err := posts.FindBookmarks(session.ID, collectionID, utils.ParseCursor(c))
+	if err != nil {
+		log.Error("Error getting bookmarks", err)
+		utils.Response(c, utils.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+func (h *UsersRouter) collections(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	collections := []*BookmarkCollection{}
+	err = db.DefaultClient.
+		Model(&models.BookmarkCollection{}).
+		Select("bookmark_collections.*, (SELECT count(*) FROM bookmarks WHERE bookmarks.collection_id = bookmark_collections.id) AS bookmarks").
+		Where(&models.BookmarkCollection{UserId: session.ID}).
+		Order("bookmark_collections.name").
+		Find(&collections).Error
+	if err != nil {
+		log.Error("Error getting bookmark collections", err)
+		utils.Response(c, utils.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(200, collections)
+}
+
+// bindCollection reads and validates a collection payload, answering the
+// request when it is not valid.
+func bindCollection(c *gin.Context) (*BookmarkCollection, bool) {
+	payload := &BookmarkCollection{}
+	err := c.ShouldBind(payload)
+	if err != nil {
+		utils.Response(c, utils.StatusBadRequest)
+		return nil, false
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(payload); err != nil {
+		errorsMap := utils.ParseErrors(err.(validator.ValidationErrors))
+		c.JSON(http.StatusBadRequest, BookmarkCollectionErrors{
+			Name: errorsMap["Name"],
+		})
+		return nil, false
+	}
+	return payload, true
+}
+
+func (h *UsersRouter) createCollection(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload, ok := bindCollection(c)
+	if !ok {
+		return
+	}
+
+	collection := &models.BookmarkCollection{UserId: session.ID, Name: payload.Name}
+	err = db.DefaultClient.Create(collection).Error
+	if err != nil {
+		log.Error("Error creating bookmark collection", err)
+		c.JSON(409, gin.H{"message": "Collection already exists"})
+		return
+	}
+
+	c.JSON(201, BookmarkCollection{
+		ID:         collection.ID,
+		Name:       collection.Name,
+		CreationAt: collection.CreationAt,
+		UpdatedAt:  collection.UpdatedAt,
+	})
+}
+
+func (h *UsersRouter) updateCollection(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	payload, ok := bindCollection(c)
+	if !ok {
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("collectionId"))
+	result := db.DefaultClient.
+		Model(&models.BookmarkCollection{}).
+		Where("id = ? AND user_id = ?", id, session.ID).
+		Update("name", payload.Name)
+	if result.Error != nil {
+		log.Error("Error updating bookmark collection", result.Error)
+		c.JSON(409, gin.H{"message": "Collection already exists"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.Response(c, utils.StatusNotFound)
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Collection updated"})
+}
+
+// deleteCollection removes a collection, its bookmarks are kept
+// uncollected.
+func (h *UsersRouter) deleteCollection(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("collectionId"))
+	err = db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Bookmark{}).
+			Where("user_id = ? AND collection_id = ?", session.ID, id).
+			Update("collection_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.
+			Where("id = ? AND user_id = ?", id, session.ID).
+			Delete(&models.BookmarkCollection{}).Error
+	})
+	if err != nil {
+		log.Error("Error deleting bookmark collection", err)
+		utils.Response(c, utils.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Collection deleted"})
+}
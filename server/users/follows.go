@@ -0,0 +1,121 @@
+package users
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juliotorresmoreno/specialist-talk-api/db"
+	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/notifications"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/posts"
+	"github.com/juliotorresmoreno/specialist-talk-api/utils"
+	"gorm.io/gorm/clause"
+)
+
+func (h *UsersRouter) follow(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	followee := &models.User{}
+	err = db.DefaultClient.
+		Where(&models.User{Username: c.Param("username")}).
+		First(followee).Error
+	if err != nil {
+		utils.Response(c, utils.StatusNotFound)
+		return
+	}
+	if followee.ID == session.ID {
+		c.JSON(400, gin.H{"message": "You can't follow yourself"})
+		return
+	}
+
+	tx := db.DefaultClient.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Follow{FollowerId: session.ID, FolloweeId: followee.ID})
+	if tx.Error != nil {
+		log.Error("Error following user", tx.Error)
+		utils.Response(c, utils.StatusInternalServerError)
+		return
+	}
+	posts.InvalidateHomeFeed(session.ID)
+	if tx.RowsAffected > 0 {
+		notifications.Notify(followee.ID, session.ID, models.NotificationFollow, "user", session.ID)
+	}
+
+	c.JSON(201, gin.H{"message": "Followed"})
+}
+
+func (h *UsersRouter) unfollow(c *gin.Context) {
+	session, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	followee := &models.User{}
+	err = db.DefaultClient.
+		Where(&models.User{Username: c.Param("username")}).
+		First(followee).Error
+	if err != nil {
+		utils.Response(c, utils.StatusNotFound)
+		return
+	}
+
+	err = db.DefaultClient.
+		Where(&models.Follow{FollowerId: session.ID, FolloweeId: followee.ID}).
+		Delete(&models.Follow{}).Error
+	if err != nil {
+		log.Error("Error unfollowing user", err)
+		utils.Response(c, utils.StatusInternalServerError)
+		return
+	}
+	posts.InvalidateHomeFeed(session.ID)
+
+	c.JSON(200, gin.H{"message": "Unfollowed"})
+}
+
+func (h *UsersRouter) followers(c *gin.Context) {
+	h.listFollows(c, "follower_id", "followee_id")
+}
+
+func (h *UsersRouter) following(c *gin.Context) {
+	h.listFollows(c, "followee_id", "follower_id")
+}
+
+// listFollows lists the users found in column of the follows whose
+// byColumn is the user in the path.
+func (h *UsersRouter) listFollows(c *gin.Context, column, byColumn string) {
+	_, err := utils.ValidateSession(c)
+	if err != nil {
+		utils.Response(c, err)
+		return
+	}
+
+	user := &models.User{}
+	err = db.DefaultClient.
+		Where(&models.User{Username: c.Param("username")}).
+		First(user).Error
+	if err != nil {
+		utils.Response(c, utils.StatusNotFound)
+		return
+	}
+
+	cursor := utils.ParseCursor(c)
+	users := []*User{}
+	tx := db.DefaultClient.Model(&models.User{}).
+		Where("users.id IN (SELECT "+column+" FROM follows WHERE "+byColumn+" = ?)", user.ID)
+	err = cursor.Apply(tx, "users.id").Find(&users).Error
+	if err != nil {
+		log.Error("Error getting follows", err)
+		utils.Response(c, utils.StatusInternalServerError)
+		return
+	}
+	if cursor.Reversed() {
+		slices.Reverse(users)
+	}
+
+	c.JSON(200, users)
+}
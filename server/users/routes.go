@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,8 +16,10 @@ import (
 	"github.com/juliotorresmoreno/specialist-talk-api/logger"
 	"github.com/juliotorresmoreno/specialist-talk-api/miniostorage"
 	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"github.com/juliotorresmoreno/specialist-talk-api/server/posts"
 	"github.com/juliotorresmoreno/specialist-talk-api/utils"
 	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
 )
 
 var log = logger.SetupLogger()
@@ -31,6 +34,16 @@ func SetupAPIRoutes(r *gin.RouterGroup) {
 	r.GET("/:username", users.findOne)
 	r.GET("/me", users.findMe)
 	r.PATCH("/me", users.updateMe)
+	r.GET("/me/analytics", users.analytics)
+	r.GET("/me/bookmarks", users.bookmarks)
+	r.GET("/me/bookmarks/collections", users.collections)
+	r.POST("/me/bookmarks/collections", users.createCollection)
+	r.PATCH("/me/bookmarks/collections/:collectionId", users.updateCollection)
+	r.DELETE("/me/bookmarks/collections/:collectionId", users.deleteCollection)
+	r.POST("/:username/follow", users.follow)
+	r.DELETE("/:username/follow", users.unfollow)
+	r.GET("/:username/followers", users.followers)
+	r.GET("/:username/following", users.following)
 }
 
 type User struct {
@@ -48,6 +61,8 @@ type User struct {
 	PositionName string     `json:"position_name"`
 	Url          string     `json:"url" validate:"omitempty,url"`
 	Description  string     `json:"description" validate:"max=1000"`
+	Expertise    []string   `json:"expertise" validate:"omitempty,max=20,dive,min=1,max=50" gorm:"-"`
+	ShowPresence *bool      `json:"show_presence,omitempty"`
 	CreationAt   time.Time  `json:"creation_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 	DeletedAt    *time.Time `json:"deleted_at"`
@@ -70,6 +85,7 @@ func (h *UsersRouter) findOne(c *gin.Context) {
 		utils.Response(c, err)
 		return
 	}
+	user.Expertise = expertiseOf(user.ID)
 
 	c.JSON(200, user)
 }
@@ -114,6 +130,7 @@ func (h *UsersRouter) findMe(c *gin.Context) {
 		utils.Response(c, tx.Error)
 		return
 	}
+	user.Expertise = expertiseOf(user.ID)
 
 	c.JSON(200, user)
 }
@@ -125,6 +142,7 @@ type UpdateValidationErrors struct {
 	PositionName string `json:"position_name,omitempty"`
 	Url          string `json:"url,omitempty"`
 	Description  string `json:"description,omitempty"`
+	Expertise    string `json:"expertise,omitempty"`
 }
 
 func (h *UsersRouter) updateMe(c *gin.Context) {
@@ -153,6 +171,7 @@ func (h *UsersRouter) updateMe(c *gin.Context) {
 			PositionName: errorsMap["PositionName"],
 			Url:          errorsMap["Url"],
 			Description:  errorsMap["Description"],
+			Expertise:    errorsMap["Expertise"],
 		}
 		log.Error("Error validating payload", err)
 		c.JSON(http.StatusBadRequest, customErrors)
@@ -190,6 +209,28 @@ func (h *UsersRouter) updateMe(c *gin.Context) {
 		return
 	}
 
+	// Hiding presence is a false value, which Updates skips.
+	if payload.ShowPresence != nil {
+		err = conn.Model(&models.User{}).
+			Where("id = ?", session.ID).
+			Update("show_presence", *payload.ShowPresence).Error
+		if err != nil {
+			log.Error("Error updating presence setting", err)
+			utils.Response(c, utils.StatusInternalServerError)
+			return
+		}
+	}
+
+	if payload.Expertise != nil {
+		affected, err := replaceExpertise(session.ID, payload.Expertise)
+		if err != nil {
+			log.Error("Error updating expertise", err)
+			utils.Response(c, utils.StatusInternalServerError)
+			return
+		}
+		posts.InvalidateHomeFeed(affected...)
+	}
+
 	c.JSON(200, gin.H{"message": "Profile updated successfully"})
 }
 
@@ -239,3 +280,55 @@ func fullName(old *utils.User, payload *User) string {
 	}
 	return ""
 }
+
+func expertiseOf(userID uint) []string {
+	tags := []string{}
+	db.DefaultClient.
+		Model(&models.UserExpertise{}).
+		Where(&models.UserExpertise{UserId: userID}).
+		Order("tag").
+		Pluck("tag", &tags)
+	return tags
+}
+
+// replaceExpertise returns the users whose home feeds change along: the
+// user and every specialist sharing one of the old or new tags.
+func replaceExpertise(userID uint, tags []string) ([]uint, error) {
+	expertise := []models.UserExpertise{}
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = utils.NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+		expertise = append(expertise, models.UserExpertise{UserId: userID, Tag: tag})
+	}
+
+	affected := []uint{}
+	err := db.DefaultClient.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.UserExpertise{}).
+			Where("user_id = ? OR tag IN (SELECT tag FROM user_expertises WHERE user_id = ?) OR tag IN ?", userID, userID, normalized).
+			Distinct().
+			Pluck("user_id", &affected).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where(&models.UserExpertise{UserId: userID}).
+			Delete(&models.UserExpertise{}).Error
+		if err != nil || len(expertise) == 0 {
+			return err
+		}
+		return tx.Create(&expertise).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(affected, userID) {
+		affected = append(affected, userID)
+	}
+	return affected, nil
+}
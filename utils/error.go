@@ -59,6 +59,18 @@ var StatusBadRequest = &HttpResponse{
 	Obj:    HttpError{Message: "Bad Request"},
 }
 
+var StatusForbidden = &HttpResponse{
+	Status: http.StatusForbidden,
+	Obj:    HttpError{Message: "Forbidden"},
+}
+
+// StatusSuspended answers the requests of a suspended user, so clients
+// can tell it apart from an expired session.
+var StatusSuspended = &HttpResponse{
+	Status: http.StatusForbidden,
+	Obj:    HttpError{Message: "Account suspended"},
+}
+
 var StatusNotFound = &HttpResponse{
 	Status: http.StatusNotFound,
 	Obj:    HttpError{Message: "Not Found"},
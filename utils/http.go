@@ -50,3 +50,11 @@ func ParseBase64File(data string) (string, error) {
 	}
 	return parts[1], nil
 }
+
+func ParseBase64FileType(data string) string {
+	parts := strings.Split(data, ";base64,")
+	if len(parts) != 2 {
+		return ""
+	}
+	return strings.TrimPrefix(parts[0], "data:")
+}
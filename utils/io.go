@@ -6,7 +6,9 @@ import (
 	"encoding/base64"
 	"fmt"
 	"image"
+	_ "image/gif"
 	"image/jpeg"
+	_ "image/png"
 	"io"
 	"os"
 	"path/filepath"
@@ -75,3 +77,11 @@ func ConvertToJPEG(in io.Reader) (*bytes.Buffer, error) {
 
 	return buff, nil
 }
+
+func ImageSize(in io.Reader) (int, int, error) {
+	config, _, err := image.DecodeConfig(in)
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
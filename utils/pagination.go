@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Cursor holds keyset pagination parameters taken from the query string.
+// Before and After are exclusive row ids, when both are empty the newest
+// rows are returned.
+type Cursor struct {
+	Limit  int
+	Before uint
+	After  uint
+}
+
+func ParseCursor(c *gin.Context) Cursor {
+	cursor := Cursor{Limit: DefaultPageLimit}
+
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		cursor.Limit = min(limit, MaxPageLimit)
+	}
+	if before, err := strconv.ParseUint(c.Query("before"), 10, 64); err == nil {
+		cursor.Before = uint(before)
+	}
+	if after, err := strconv.ParseUint(c.Query("after"), 10, 64); err == nil {
+		cursor.After = uint(after)
+	}
+
+	return cursor
+}
+
+// Apply restricts tx to the page described by the cursor. Rows are ordered
+// newest first, except when paginating forward with After, in which case
+// they come oldest first and the caller must reverse them.
+func (cursor Cursor) Apply(tx *gorm.DB, column string) *gorm.DB {
+	if cursor.Before > 0 {
+		tx = tx.Where(column+" < ?", cursor.Before)
+	}
+	if cursor.After > 0 {
+		tx = tx.Where(column+" > ?", cursor.After).Order(column + " ASC")
+	} else {
+		tx = tx.Order(column + " DESC")
+	}
+	return tx.Limit(cursor.Limit)
+}
+
+func (cursor Cursor) Reversed() bool {
+	return cursor.After > 0
+}
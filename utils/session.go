@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/juliotorresmoreno/specialist-talk-api/db"
 	"github.com/juliotorresmoreno/specialist-talk-api/models"
+	"gorm.io/gorm"
 )
 
 type User struct {
@@ -18,6 +19,14 @@ type User struct {
 	Username  string `json:"username"`
 	PhotoURL  string `json:"photo_url"`
 	Phone     string `json:"phone"`
+	Rol       string `json:"rol"`
+
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+// IsModerator tells whether the user can review content of other users.
+func (u *User) IsModerator() bool {
+	return u.Rol == "moderator" || u.Rol == "admin"
 }
 
 type Session struct {
@@ -39,15 +48,30 @@ func ValidateSession(c *gin.Context) (*User, error) {
 	conn := db.DefaultClient
 	user := &User{}
 	err = conn.Model(&models.User{}).First(user, "email = ? AND deleted_at IS NULL", email).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, StatusUnauthorized
+	}
 	if err != nil {
 		return nil, StatusInternalServerError
 	}
+	if user.SuspendedUntil != nil && user.SuspendedUntil.After(time.Now()) {
+		return nil, StatusSuspended
+	}
 
 	db.DefaultCache.Set(context.Background(), "session-"+token, email, 24*time.Hour)
 
 	return user, nil
 }
 
+// OptionalSession is ValidateSession for endpoints open to anonymous
+// readers, it returns a nil user when no token was sent.
+func OptionalSession(c *gin.Context) (*User, error) {
+	if _, err := GetToken(c); err != nil {
+		return nil, nil
+	}
+	return ValidateSession(c)
+}
+
 func ParseSession(token string, user *models.User) *Session {
 	return &Session{
 		Token: token,
@@ -59,6 +83,7 @@ func ParseSession(token string, user *models.User) *Session {
 			Email:     user.Email,
 			PhotoURL:  user.PhotoURL,
 			Phone:     user.Phone,
+			Rol:       user.Rol,
 		},
 	}
 }
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var hashtagRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&])#([\p{L}\p{N}_]{1,100})`)
+
+// NormalizeTag lower cases a tag and strips the leading #.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// ParseHashtags returns the distinct normalized #hashtags of content in the
+// order they first appear.
+func ParseHashtags(content string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagRegex.FindAllStringSubmatch(content, -1) {
+		tag := NormalizeTag(match[1])
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+var mentionRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([\p{L}\p{N}_.\-]{1,100})`)
+
+type MentionRange struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// ParseMentions returns every @username of content with its position,
+// offsets are counted in characters and End is exclusive.
+func ParseMentions(content string) []MentionRange {
+	mentions := []MentionRange{}
+	for _, match := range mentionRegex.FindAllStringSubmatchIndex(content, -1) {
+		username := strings.TrimRight(content[match[2]:match[3]], ".-")
+		if username == "" {
+			continue
+		}
+		start := utf8.RuneCountInString(content[:match[2]-1])
+		mentions = append(mentions, MentionRange{
+			Username: username,
+			Start:    start,
+			End:      start + 1 + utf8.RuneCountInString(username),
+		})
+	}
+	return mentions
+}